@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/advisor/armadvisor"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/webdevops/go-common/utils/to"
+
+	"github.com/webdevops/azure-resourcemanager-exporter/config"
+)
+
+type (
+	// advisorRecommendationStore shares the advisor recommendations between the advisor and defender collector
+	// so the recommendations are only fetched once per subscription and collect cycle
+	advisorRecommendationStore struct {
+		lock          sync.Mutex
+		subscriptions map[string]*advisorRecommendationStoreEntry
+	}
+
+	advisorRecommendationStoreEntry struct {
+		lock            sync.Mutex
+		fetchTime       time.Time
+		recommendations []*armadvisor.ResourceRecommendationBase
+	}
+)
+
+var (
+	azureAdvisorRecommendations = &advisorRecommendationStore{
+		subscriptions: map[string]*advisorRecommendationStoreEntry{},
+	}
+)
+
+// get returns the store entry of the subscription
+func (s *advisorRecommendationStore) get(subscriptionID string) *advisorRecommendationStoreEntry {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, exists := s.subscriptions[subscriptionID]; !exists {
+		s.subscriptions[subscriptionID] = &advisorRecommendationStoreEntry{}
+	}
+	return s.subscriptions[subscriptionID]
+}
+
+// Fetch returns the advisor recommendations of the subscription, recommendations are fetched from the api
+// if the previous fetch is older than the cache time (eg. by the other collector in the same collect cycle)
+func (s *advisorRecommendationStore) Fetch(ctx context.Context, subscription *armsubscriptions.Subscription) []*armadvisor.ResourceRecommendationBase {
+	entry := s.get(to.StringLower(subscription.SubscriptionID))
+
+	// lock subscription entry, parallel fetches wait for the running one
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	if !entry.fetchTime.IsZero() && time.Since(entry.fetchTime) < advisorRecommendationCacheTime() {
+		return entry.recommendations
+	}
+
+	client, err := armadvisor.NewRecommendationsClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	fetchTime := time.Now()
+	recommendations := []*armadvisor.ResourceRecommendationBase{}
+
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		result, err := pager.NextPage(ctx)
+		if err != nil {
+			panic(err)
+		}
+
+		recommendations = append(recommendations, result.Value...)
+	}
+
+	entry.fetchTime = fetchTime
+	entry.recommendations = recommendations
+
+	return recommendations
+}
+
+// advisorRecommendationCacheTime returns the time how long fetched recommendations are reused
+// (half of the shortest scrape time of the collectors using advisor recommendations)
+func advisorRecommendationCacheTime() time.Duration {
+	var cacheTime time.Duration
+	for _, collectorConfig := range []*config.CollectorBase{Config.Collectors.Advisor.CollectorBase, Config.Collectors.Defender.CollectorBase} {
+		if !collectorConfig.IsEnabled() {
+			continue
+		}
+
+		if cacheTime == 0 || *collectorConfig.ScrapeTime < cacheTime {
+			cacheTime = *collectorConfig.ScrapeTime
+		}
+	}
+
+	return cacheTime / 2
+}
+
+// advisorRecommendationCategory returns the lowercase category of the recommendation
+func advisorRecommendationCategory(recommendation *armadvisor.ResourceRecommendationBase) string {
+	if recommendation.Properties != nil && recommendation.Properties.Category != nil {
+		return strings.ToLower(string(*recommendation.Properties.Category))
+	}
+	return ""
+}
+
+// filterAdvisorRecommendationsByCategory returns only recommendations of the categories (all recommendations if no category is set)
+func filterAdvisorRecommendationsByCategory(recommendations []*armadvisor.ResourceRecommendationBase, categories []string) []*armadvisor.ResourceRecommendationBase {
+	if len(categories) == 0 {
+		return recommendations
+	}
+
+	ret := []*armadvisor.ResourceRecommendationBase{}
+	for _, recommendation := range recommendations {
+		category := advisorRecommendationCategory(recommendation)
+		for _, val := range categories {
+			if strings.EqualFold(val, category) {
+				ret = append(ret, recommendation)
+				break
+			}
+		}
+	}
+	return ret
+}
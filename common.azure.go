@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	armruntime "github.com/Azure/azure-sdk-for-go/sdk/azcore/arm/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// armRestUrl builds the Azure ResourceManager url for path (eg. scope with provider path) and query
+func armRestUrl(path string, query url.Values) string {
+	endpoint := AzureClient.GetCloudConfig().Services[cloud.ResourceManager].Endpoint
+	return runtime.JoinPaths(endpoint, path) + "?" + query.Encode()
+}
+
+// armRestGet sends a GET request to the Azure ResourceManager api (for apis without sdk client)
+// and decodes the json response into result, returns the status code of the response
+func armRestGet(ctx context.Context, requestUrl string, result interface{}) (int, error) {
+	pl, err := armruntime.NewPipeline("azurerm-rest", gitTag, AzureClient.GetCred(), runtime.PipelineOptions{}, AzureClient.NewArmClientOptions())
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := runtime.NewRequest(ctx, http.MethodGet, requestUrl)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := pl.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		resp.Body.Close() // nolint:errcheck
+		return resp.StatusCode, fmt.Errorf(`unexpected status code %v for %v`, resp.StatusCode, req.Raw().URL.Path)
+	}
+
+	return resp.StatusCode, runtime.UnmarshalAsJSON(resp, result)
+}
+
+// isAzureUnsupportedScopeStatusCode returns true if the status code of the api response indicates
+// that the api is not supported for the scope (eg. billing account type or disabled feature)
+func isAzureUnsupportedScopeStatusCode(statusCode int) bool {
+	return statusCode == http.StatusBadRequest || statusCode == http.StatusNotFound
+}
+
+// isAzureUnsupportedScopeError returns true if the error is an error response of the api that the api is not supported for the scope,
+// other errors (eg. authorization, throttling or server errors) are not handled as unsupported
+func isAzureUnsupportedScopeError(err error) bool {
+	var responseErr *azcore.ResponseError
+	return errors.As(err, &responseErr) && isAzureUnsupportedScopeStatusCode(responseErr.StatusCode)
+}
+
+// isAzureForbiddenError returns true if the error is an error response of the api that access is denied
+func isAzureForbiddenError(err error) bool {
+	var responseErr *azcore.ResponseError
+	return errors.As(err, &responseErr) && responseErr.StatusCode == http.StatusForbidden
+}
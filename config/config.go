@@ -9,18 +9,24 @@ type (
 	Config struct {
 		Azure      Azure `json:"azure"`
 		Collectors struct {
-			General        CollectorBase           `json:"general"`
-			Resource       CollectorBase           `json:"resource"`
-			Quota          CollectorQuota          `json:"quota"`
-			Advisor        CollectorAdvisor        `json:"advisor"`
-			Defender       CollectorBase           `json:"defender"`
-			ResourceHealth CollectorResourceHealth `json:"resourceHealth"`
-			Iam            CollectorBase           `json:"iam"`
-			Graph          CollectorGraph          `json:"graph"`
-			Costs          CollectorCosts          `json:"costs"`
-			Budgets        CollectorBudgets        `json:"budgets"`
-			Reservation    CollectorReservation    `json:"reservation"`
-			Portscan       CollectorPortscan       `json:"portscan"`
+			General                CollectorBase                   `json:"general"`
+			Resource               CollectorBase                   `json:"resource"`
+			Quota                  CollectorQuota                  `json:"quota"`
+			Advisor                CollectorAdvisor                `json:"advisor"`
+			Defender               CollectorDefender               `json:"defender"`
+			DefenderAlerts         CollectorDefenderAlerts         `json:"defenderAlerts"`
+			ResourceHealth         CollectorResourceHealth         `json:"resourceHealth"`
+			ServiceHealth          CollectorServiceHealth          `json:"serviceHealth"`
+			Iam                    CollectorBase                   `json:"iam"`
+			Graph                  CollectorGraph                  `json:"graph"`
+			Costs                  CollectorCosts                  `json:"costs"`
+			Budgets                CollectorBudgets                `json:"budgets"`
+			CostAlerts             CollectorCostAlerts             `json:"costAlerts"`
+			Reservation            CollectorReservation            `json:"reservation"`
+			SavingsPlan            CollectorSavingsPlan            `json:"savingsPlan"`
+			Billing                CollectorBilling                `json:"billing"`
+			PurchaseRecommendation CollectorPurchaseRecommendation `json:"purchaseRecommendation"`
+			Portscan               CollectorPortscan               `json:"portscan"`
 		} `json:"collectors"`
 	}
 
@@ -50,6 +56,15 @@ func (c *CollectorBase) IsEnabled() bool {
 	return c.ScrapeTime != nil && c.ScrapeTime.Seconds() > 0
 }
 
+func (c *Config) Validate() []error {
+	var errList []error
+	errList = append(errList, c.Collectors.Costs.Validate()...)
+	errList = append(errList, c.Collectors.Reservation.Validate()...)
+	errList = append(errList, c.Collectors.Defender.Validate()...)
+	errList = append(errList, c.Collectors.DefenderAlerts.Validate()...)
+	return errList
+}
+
 func (c *Config) GetJson() []byte {
 	jsonBytes, err := json.Marshal(c)
 	if err != nil {
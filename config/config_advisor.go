@@ -1,10 +1,60 @@
 package config
 
+import (
+	"strings"
+	"time"
+)
+
 type (
 	CollectorAdvisor struct {
 		*CollectorBase `yaml:",inline"`
 
 		ProblemMaxLength  int `json:"problemMaxLength"`
 		SolutionMaxLength int `json:"solutionMaxLength"`
+
+		// recommendation categories (eg. Cost, Security, HighAvailability, Performance, OperationalExcellence), defaults to all categories
+		Categories []string `json:"categories"`
+
+		// opt-in labels of recommendations (from extended properties)
+		Labels struct {
+			// recommended sku (eg. target sku of rightsizing or reservation recommendations)
+			RecommendedSku bool `json:"recommendedSku"`
+
+			// term of reservation or savings plan recommendations
+			Term bool `json:"term"`
+		} `json:"labels"`
+
+		// opt-in regeneration of recommendations (write request, async, new recommendations are available in the next run)
+		Generate struct {
+			Enabled  bool           `json:"enabled"`
+			Interval *time.Duration `json:"interval"`
+		} `json:"generate"`
+
+		// opt-in advisor suppressions (snoozed/dismissed recommendations)
+		Suppressions struct {
+			Enabled bool `json:"enabled"`
+		} `json:"suppressions"`
+
+		// opt-in advisor score (overall and per category)
+		Score struct {
+			Enabled bool `json:"enabled"`
+
+			// aggregation level of score history: day, week, month or none
+			HistoryAggregation string `json:"historyAggregation"`
+		} `json:"score"`
 	}
 )
+
+func (c *CollectorAdvisor) GetScoreHistoryAggregation() string {
+	if c.Score.HistoryAggregation != "" {
+		return strings.ToLower(c.Score.HistoryAggregation)
+	}
+	return "week"
+}
+
+func (c *CollectorAdvisor) GetGenerateInterval() time.Duration {
+	if c.Generate.Interval != nil {
+		return *c.Generate.Interval
+	}
+	return 24 * time.Hour
+}
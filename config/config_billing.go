@@ -0,0 +1,20 @@
+package config
+
+type (
+	CollectorBilling struct {
+		*CollectorBase `yaml:",inline"`
+
+		// billing account or billing profile scopes
+		Scopes []string `json:"scopes"`
+
+		// invoices of the last days
+		InvoiceDays int `json:"invoiceDays"`
+	}
+)
+
+func (c *CollectorBilling) GetInvoiceDays() int {
+	if c.InvoiceDays > 0 {
+		return c.InvoiceDays
+	}
+	return 90
+}
@@ -5,5 +5,14 @@ type (
 		*CollectorBase `yaml:",inline"`
 
 		Scopes []string `json:"scopes"`
+
+		// discovery of budget scopes (only used if no scopes are configured)
+		Discovery struct {
+			// collect budgets of all resource groups of each subscription
+			ResourceGroups bool `json:"resourceGroups"`
+
+			// collect budgets of all management groups (management group tree)
+			ManagementGroups bool `json:"managementGroups"`
+		} `json:"discovery"`
 	}
 )
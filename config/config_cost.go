@@ -1,20 +1,43 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/costmanagement/armcostmanagement"
+	yaml "github.com/goccy/go-yaml"
+)
+
+const (
+	CostsQueryModeUsage    = "usage"
+	CostsQueryModeForecast = "forecast"
+
+	CostsQueryDimensionTypeDimension = "dimension"
+	CostsQueryDimensionTypeTag       = "tag"
 )
 
 type (
 	CollectorCosts struct {
 		*CollectorBase `yaml:",inline"`
 
+		// delay after each cost query per scope worker
 		RequestDelay time.Duration `json:"requestDelay"`
 
-		Queries []CollectorCostsQuery `json:"queries"`
+		// number of scopes queried in parallel
+		Parallel  int                     `json:"parallel"`
+		RateLimit CollectorCostsRateLimit `json:"rateLimit"`
+
+		// optional, conversion of costs into one currency
+		Currency *CollectorCostsCurrency `json:"currency"`
+
+		Queries    []CollectorCostsQuery `json:"queries"`
+		QueryPaths []string              `json:"queryPaths"`
 	}
 
 	CollectorCostsQuery struct {
@@ -23,7 +46,7 @@ type (
 		Scopes        *[]string                      `json:"scopes"`
 		Subscriptions *[]string                      `json:"subscriptions"`
 		TimeFrames    []string                       `json:"timeFrames"`
-		Dimensions    []string                       `json:"dimensions"`
+		Dimensions    []CollectorCostsQueryDimension `json:"dimensions"`
 		ExportType    string                         `json:"exportType"`
 		Filter        *armcostmanagement.QueryFilter `json:"filter"`
 		Granularity   string                         `json:"granularity"`
@@ -31,8 +54,73 @@ type (
 		Labels        map[string]string              `json:"labels"`
 		TimePeriod    *CollectorCostsQueryTimePeriod `json:"timePeriod"`
 
+		// usage (default) or forecast
+		Mode              string `json:"mode"`
+		IncludeActualCost *bool  `json:"includeActualCost"`
+
+		// incremental daily history (instead of date labels)
+		History *CollectorCostsQueryHistory `json:"history"`
+
+		// showback allocation of shared costs (exported as additional allocated metric)
+		Allocation []CollectorCostsQueryAllocation `json:"allocation"`
+
 		config *configCollectorCostsQueryConfig
 	}
+	CollectorCostsQueryDimension struct {
+		// dimension (default) or tag
+		Type  string `json:"type"`
+		Name  string `json:"name"`
+		Label string `json:"label"`
+	}
+
+	CollectorCostsQueryAllocation struct {
+		// label (of a dimension) which is used to match sources and targets
+		Label string `json:"label"`
+
+		// label values which costs are shared and allocated to the targets
+		Sources []string `json:"sources"`
+
+		// fixed split, target label value and percentage (sum must be 100)
+		Targets map[string]float64 `json:"targets"`
+
+		// proportional split based on the values of another query (can also be the same query)
+		ProportionalTo *string `json:"proportionalTo"`
+		// label of the proportionalTo query, defaults to label
+		ProportionalLabel string `json:"proportionalLabel"`
+	}
+
+	CollectorCostsQueryHistory struct {
+		// days fetched on first run and kept in history
+		Days int `json:"days"`
+		// recent days which are fetched again on every run as costs are still settling
+		SettleDays int `json:"settleDays"`
+		// optional, history is written to this file in OpenMetrics format (with timestamps)
+		OpenMetricsFile string `json:"openMetricsFile"`
+	}
+
+	CollectorCostsRateLimit struct {
+		// requests are paced if the remaining qpu or requests are at or below these values
+		MinQpuRemaining      *float64 `json:"minQpuRemaining"`
+		MinRequestsRemaining *float64 `json:"minRequestsRemaining"`
+
+		// wait time if rate limit headroom is exhausted
+		Backoff *time.Duration `json:"backoff"`
+	}
+
+	CollectorCostsCurrency struct {
+		// target currency (eg. EUR)
+		Target string `json:"target"`
+
+		// exchange rates, 1 unit of currency = rate units of target currency
+		Rates map[string]float64 `json:"rates"`
+
+		// optional, yaml/json file with exchange rates (same format as rates), overrides static rates
+		RatesFile string `json:"ratesFile"`
+
+		// reload interval of rates file
+		ReloadInterval *time.Duration `json:"reloadInterval"`
+	}
+
 	CollectorCostsQueryTimePeriod struct {
 		From         *time.Time     `json:"from"`
 		FromDuration *time.Duration `json:"fromDuration"`
@@ -46,11 +134,343 @@ type (
 	}
 
 	configCollectorCostsQueryConfigDimension struct {
+		Type      string
 		Dimension string
 		Label     string
 	}
 )
 
+func (d *CollectorCostsQueryDimension) UnmarshalJSON(data []byte) error {
+	var (
+		valString    string
+		valDimension struct {
+			Type  string `json:"type"`
+			Name  string `json:"name"`
+			Label string `json:"label"`
+		}
+	)
+
+	// try string first (ResourceGroupName, dimension:ResourceGroupName or tag:owner)
+	if err := json.Unmarshal(data, &valString); err == nil {
+		d.Type = CostsQueryDimensionTypeDimension
+		d.Name = valString
+		if dimensionType, dimensionName, found := strings.Cut(valString, ":"); found {
+			d.Type = strings.ToLower(dimensionType)
+			d.Name = dimensionName
+		}
+		return nil
+	}
+
+	// try full version
+	err := json.Unmarshal(data, &valDimension)
+	if err != nil {
+		return err
+	}
+	d.Type = strings.ToLower(valDimension.Type)
+	d.Name = valDimension.Name
+	d.Label = valDimension.Label
+	if d.Type == "" {
+		d.Type = CostsQueryDimensionTypeDimension
+	}
+	return nil
+}
+
+func (d *CollectorCostsQueryDimension) String() string {
+	if d.Type == CostsQueryDimensionTypeDimension {
+		return d.Name
+	}
+
+	return fmt.Sprintf(`%v:%v`, d.Type, d.Name)
+}
+
+func (c *CollectorCosts) Validate() []error {
+	var errList []error
+
+	queryNames := map[string]bool{}
+	for _, query := range c.Queries {
+		if query.Name == "" {
+			errList = append(errList, fmt.Errorf(`cost query without name found`))
+			continue
+		}
+
+		if !prometheusLabelNameRegExp.MatchString(query.Name) {
+			errList = append(errList, fmt.Errorf(`cost query "%v": name is not valid for a prometheus metric name`, query.Name))
+		}
+
+		if queryNames[query.Name] {
+			errList = append(errList, fmt.Errorf(`cost query "%v" is defined multiple times`, query.Name))
+		}
+		queryNames[query.Name] = true
+
+		errList = append(errList, query.Validate()...)
+	}
+
+	for _, query := range c.Queries {
+		for _, allocation := range query.Allocation {
+			if allocation.ProportionalTo == nil {
+				continue
+			}
+
+			proportionalQuery := c.GetQuery(*allocation.ProportionalTo)
+			if proportionalQuery == nil {
+				errList = append(errList, fmt.Errorf(`cost query "%v": allocation proportionalTo query "%v" not found`, query.Name, *allocation.ProportionalTo))
+				continue
+			}
+
+			if !slices.Contains(proportionalQuery.getDimensionLabels(), allocation.GetProportionalLabel()) {
+				errList = append(errList, fmt.Errorf(`cost query "%v": allocation label "%v" not found in dimensions of query "%v"`, query.Name, allocation.GetProportionalLabel(), proportionalQuery.Name))
+			}
+		}
+	}
+
+	if c.Currency != nil {
+		if c.Currency.Target == "" {
+			errList = append(errList, fmt.Errorf(`cost currency conversion: target currency is required`))
+		}
+
+		if len(c.Currency.Rates) == 0 && c.Currency.RatesFile == "" {
+			errList = append(errList, fmt.Errorf(`cost currency conversion: rates or ratesFile is required`))
+		}
+
+		if _, err := c.Currency.LoadRates(); err != nil {
+			errList = append(errList, fmt.Errorf(`cost currency conversion: %w`, err))
+		}
+	}
+
+	return errList
+}
+
+// LoadQueriesFromEnv adds cost queries from environment variables starting with prefix
+// every variable contains one query or a list of queries as yaml or json,
+// the name of the query defaults to the variable name without prefix
+func (c *CollectorCosts) LoadQueriesFromEnv(prefix string) error {
+	envList := os.Environ()
+	slices.Sort(envList)
+
+	for _, env := range envList {
+		envName, envValue, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(envName, prefix) || strings.TrimSpace(envValue) == "" {
+			continue
+		}
+
+		defaultName := strings.ToLower(strings.TrimPrefix(envName, prefix))
+		if err := c.addQueries(fmt.Sprintf(`env:%v`, envName), defaultName, []byte(envValue)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadQueriesFromPaths adds cost queries from the files in queryPaths
+// a path can be a file or a directory (all *.yaml, *.yml and *.json files are used),
+// every file contains one query or a list of queries, the name of the query defaults to the filename without extension
+func (c *CollectorCosts) LoadQueriesFromPaths() error {
+	for _, path := range c.QueryPaths {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf(`unable to read cost query path "%v": %w`, path, err)
+		}
+
+		fileList := []string{path}
+		if stat.IsDir() {
+			fileList = []string{}
+			for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+				matches, err := filepath.Glob(filepath.Join(path, pattern))
+				if err != nil {
+					return err
+				}
+				fileList = append(fileList, matches...)
+			}
+			slices.Sort(fileList)
+		}
+
+		for _, file := range fileList {
+			/* #nosec */
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf(`unable to read cost query file "%v": %w`, file, err)
+			}
+
+			defaultName := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			if err := c.addQueries(fmt.Sprintf(`file:%v`, file), defaultName, content); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *CollectorCosts) addQueries(source, defaultName string, content []byte) error {
+	var (
+		raw       interface{}
+		queryList []CollectorCostsQuery
+	)
+
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return fmt.Errorf(`unable to parse cost query from %v: %w`, source, err)
+	}
+
+	if _, isList := raw.([]interface{}); isList {
+		if err := yaml.UnmarshalWithOptions(content, &queryList, yaml.Strict(), yaml.UseJSONUnmarshaler()); err != nil {
+			return fmt.Errorf(`unable to parse cost queries from %v: %w`, source, err)
+		}
+	} else {
+		query := CollectorCostsQuery{}
+		if err := yaml.UnmarshalWithOptions(content, &query, yaml.Strict(), yaml.UseJSONUnmarshaler()); err != nil {
+			return fmt.Errorf(`unable to parse cost query from %v: %w`, source, err)
+		}
+
+		if query.Name == "" {
+			query.Name = prometheusLabelReplacerRegExp.ReplaceAllString(defaultName, "_")
+		}
+		queryList = append(queryList, query)
+	}
+
+	for _, query := range queryList {
+		for _, existingQuery := range c.Queries {
+			if existingQuery.Name == query.Name {
+				return fmt.Errorf(`cost query "%v" from %v is already defined`, query.Name, source)
+			}
+		}
+
+		c.Queries = append(c.Queries, query)
+	}
+
+	return nil
+}
+
+func (q *CollectorCostsQuery) Validate() []error {
+	var errList []error
+
+	labelNames := map[string]bool{}
+	for _, dimension := range q.GetConfig().Dimensions {
+		switch dimension.Type {
+		case CostsQueryDimensionTypeDimension, CostsQueryDimensionTypeTag:
+		default:
+			errList = append(errList, fmt.Errorf(`cost query "%v": dimension type "%v" of dimension "%v" is not supported (dimension or tag)`, q.Name, dimension.Type, dimension.Dimension))
+		}
+
+		if dimension.Dimension == "" {
+			errList = append(errList, fmt.Errorf(`cost query "%v": dimension without name found`, q.Name))
+		}
+
+		if !prometheusLabelNameRegExp.MatchString(dimension.Label) {
+			errList = append(errList, fmt.Errorf(`cost query "%v": label "%v" of dimension "%v" is not a valid prometheus label name`, q.Name, dimension.Label, dimension.Dimension))
+		}
+
+		if labelNames[dimension.Label] {
+			errList = append(errList, fmt.Errorf(`cost query "%v": label "%v" is used by multiple dimensions`, q.Name, dimension.Label))
+		}
+		labelNames[dimension.Label] = true
+	}
+
+	if q.IsHistory() {
+		if q.Granularity != "Daily" {
+			errList = append(errList, fmt.Errorf(`cost query "%v": history needs granularity "Daily"`, q.Name))
+		}
+
+		if q.IsForecast() {
+			errList = append(errList, fmt.Errorf(`cost query "%v": history is not supported for forecast`, q.Name))
+		}
+
+		if len(q.TimeFrames) > 0 && !slices.Equal(q.TimeFrames, []string{"Custom"}) {
+			errList = append(errList, fmt.Errorf(`cost query "%v": timeFrames are managed by history, use "Custom" or leave empty`, q.Name))
+		}
+
+		if q.TimePeriod != nil {
+			errList = append(errList, fmt.Errorf(`cost query "%v": timePeriod is managed by history and cannot be set`, q.Name))
+		}
+
+		if q.History.GetSettleDays() > q.History.GetDays() {
+			errList = append(errList, fmt.Errorf(`cost query "%v": history settleDays cannot be greater than days`, q.Name))
+		}
+	}
+
+	for _, allocation := range q.Allocation {
+		if !labelNames[allocation.Label] {
+			errList = append(errList, fmt.Errorf(`cost query "%v": allocation label "%v" is not a dimension label of the query`, q.Name, allocation.Label))
+		}
+
+		if len(allocation.Sources) == 0 {
+			errList = append(errList, fmt.Errorf(`cost query "%v": allocation without sources found`, q.Name))
+		}
+
+		switch {
+		case len(allocation.Targets) > 0 && allocation.ProportionalTo != nil:
+			errList = append(errList, fmt.Errorf(`cost query "%v": allocation can either use targets or proportionalTo`, q.Name))
+		case len(allocation.Targets) > 0:
+			percentageSum := 0.0
+			for target, percentage := range allocation.Targets {
+				if percentage <= 0 {
+					errList = append(errList, fmt.Errorf(`cost query "%v": allocation target "%v" needs a positive percentage`, q.Name, target))
+				}
+				if allocation.IsSource(target) {
+					errList = append(errList, fmt.Errorf(`cost query "%v": allocation target "%v" is also a source`, q.Name, target))
+				}
+				percentageSum += percentage
+			}
+
+			if math.Abs(percentageSum-100) > 0.001 {
+				errList = append(errList, fmt.Errorf(`cost query "%v": allocation target percentages must sum up to 100 (is %v)`, q.Name, percentageSum))
+			}
+		case allocation.ProportionalTo == nil:
+			errList = append(errList, fmt.Errorf(`cost query "%v": allocation needs targets or proportionalTo`, q.Name))
+		}
+	}
+
+	if q.IsForecast() {
+		for _, timeframe := range q.TimeFrames {
+			if !slices.Contains(armcostmanagement.PossibleForecastTimeframeTypeValues(), armcostmanagement.ForecastTimeframeType(timeframe)) {
+				errList = append(errList, fmt.Errorf(`cost query "%v": timeframe "%v" is not supported by forecast api`, q.Name, timeframe))
+			}
+		}
+
+		switch q.Granularity {
+		case "", "None", "Daily":
+		default:
+			errList = append(errList, fmt.Errorf(`cost query "%v": granularity "%v" is not supported by forecast api (None or Daily)`, q.Name, q.Granularity))
+		}
+	}
+
+	return errList
+}
+
+func (c *CollectorCosts) GetQuery(name string) *CollectorCostsQuery {
+	for i := range c.Queries {
+		if c.Queries[i].Name == name {
+			return &c.Queries[i]
+		}
+	}
+	return nil
+}
+
+// IsSource returns true if value is a source of the allocation (case-insensitive)
+func (a *CollectorCostsQueryAllocation) IsSource(value string) bool {
+	for _, source := range a.Sources {
+		if strings.EqualFold(source, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *CollectorCostsQueryAllocation) GetProportionalLabel() string {
+	if a.ProportionalLabel != "" {
+		return a.ProportionalLabel
+	}
+	return a.Label
+}
+
+func (q *CollectorCostsQuery) getDimensionLabels() []string {
+	var ret []string
+	for _, dimension := range q.GetConfig().Dimensions {
+		ret = append(ret, dimension.Label)
+	}
+	return ret
+}
+
 func (q *CollectorCostsQuery) GetMetricName() string {
 	return fmt.Sprintf(`azurerm_costs_%v`, q.Name)
 }
@@ -58,11 +478,130 @@ func (q *CollectorCostsQuery) GetMetricName() string {
 func (q *CollectorCostsQuery) GetMetricHelp() string {
 	if q.Help != nil {
 		return *q.Help
+	} else if q.IsForecast() {
+		return fmt.Sprintf(`Azure ResourceManager costmanagement forecast with dimensions %v`, strings.Join(q.getDimensionNames(), ", "))
 	} else {
-		return fmt.Sprintf(`Azure ResourceManager costmanagement query with dimensions %v`, strings.Join(q.Dimensions, ", "))
+		return fmt.Sprintf(`Azure ResourceManager costmanagement query with dimensions %v`, strings.Join(q.getDimensionNames(), ", "))
+	}
+}
+
+func (q *CollectorCostsQuery) getDimensionNames() []string {
+	ret := make([]string, len(q.Dimensions))
+	for i, dimension := range q.Dimensions {
+		ret[i] = dimension.String()
+	}
+	return ret
+}
+
+func (q *CollectorCostsQuery) IsForecast() bool {
+	return strings.EqualFold(q.Mode, CostsQueryModeForecast)
+}
+
+func (q *CollectorCostsQuery) IsHistory() bool {
+	return q.History != nil
+}
+
+func (q *CollectorCostsQuery) GetTimeFrames() []string {
+	if q.IsHistory() {
+		// time period is managed by history
+		return []string{"Custom"}
+	}
+
+	return q.TimeFrames
+}
+
+func (c *CollectorCosts) GetParallel() int {
+	if c.Parallel > 0 {
+		return c.Parallel
+	}
+	return 4
+}
+
+func (c *CollectorCostsCurrency) GetTarget() string {
+	return strings.ToUpper(c.Target)
+}
+
+func (c *CollectorCostsCurrency) GetReloadInterval() time.Duration {
+	if c.ReloadInterval != nil {
+		return *c.ReloadInterval
+	}
+	return 1 * time.Hour
+}
+
+// LoadRates returns the static exchange rates merged with the rates from the rates file,
+// currencies are returned in uppercase
+func (c *CollectorCostsCurrency) LoadRates() (map[string]float64, error) {
+	rates := map[string]float64{}
+	for currency, rate := range c.Rates {
+		rates[strings.ToUpper(currency)] = rate
+	}
+
+	if c.RatesFile != "" {
+		/* #nosec */
+		content, err := os.ReadFile(c.RatesFile)
+		if err != nil {
+			return nil, fmt.Errorf(`unable to read currency rates file "%v": %w`, c.RatesFile, err)
+		}
+
+		fileRates := map[string]float64{}
+		if err := yaml.Unmarshal(content, &fileRates); err != nil {
+			return nil, fmt.Errorf(`unable to parse currency rates file "%v": %w`, c.RatesFile, err)
+		}
+
+		for currency, rate := range fileRates {
+			rates[strings.ToUpper(currency)] = rate
+		}
+	}
+
+	for currency, rate := range rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf(`invalid currency rate for "%v": %v`, currency, rate)
+		}
+	}
+
+	// target currency is always known
+	rates[c.GetTarget()] = 1
+
+	return rates, nil
+}
+
+func (r *CollectorCostsRateLimit) GetMinQpuRemaining() float64 {
+	if r.MinQpuRemaining != nil {
+		return *r.MinQpuRemaining
+	}
+	return 2
+}
+
+func (r *CollectorCostsRateLimit) GetMinRequestsRemaining() float64 {
+	if r.MinRequestsRemaining != nil {
+		return *r.MinRequestsRemaining
+	}
+	return 2
+}
+
+func (r *CollectorCostsRateLimit) GetBackoff() time.Duration {
+	if r.Backoff != nil {
+		return *r.Backoff
+	}
+	return 10 * time.Second
+}
+
+func (h *CollectorCostsQueryHistory) GetDays() int {
+	if h.Days > 0 {
+		return h.Days
+	}
+	return 30
+}
+
+func (h *CollectorCostsQueryHistory) GetSettleDays() int {
+	if h.SettleDays > 0 {
+		return h.SettleDays
 	}
+	return 3
 }
 
+// GetConfig returns the processed query config, it's built on first call
+// (collector setup) and must not be built concurrently
 func (q *CollectorCostsQuery) GetConfig() *configCollectorCostsQueryConfig {
 	if q.config == nil {
 		q.config = &configCollectorCostsQueryConfig{
@@ -70,19 +609,23 @@ func (q *CollectorCostsQuery) GetConfig() *configCollectorCostsQueryConfig {
 		}
 
 		for _, dimension := range q.Dimensions {
-			labelName := lowerFirst(prometheusLabelReplacerRegExp.ReplaceAllString(dimension, "_"))
+			labelName := lowerFirst(prometheusLabelReplacerRegExp.ReplaceAllString(dimension.String(), "_"))
 
 			switch {
-			case strings.EqualFold(dimension, "ResourceGroupName"):
+			case dimension.Label != "":
+				labelName = dimension.Label
+			case dimension.Type != CostsQueryDimensionTypeDimension:
+			case strings.EqualFold(dimension.Name, "ResourceGroupName"):
 				labelName = "resourceGroup"
-			case strings.EqualFold(dimension, "ResourceId"):
+			case strings.EqualFold(dimension.Name, "ResourceId"):
 				labelName = "resourceID"
 			}
 
 			q.config.Dimensions = append(
 				q.config.Dimensions,
 				configCollectorCostsQueryConfigDimension{
-					Dimension: dimension,
+					Type:      dimension.Type,
+					Dimension: dimension.Name,
 					Label:     labelName,
 				},
 			)
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"strings"
+)
+
+type (
+	CollectorCostAlerts struct {
+		*CollectorBase `yaml:",inline"`
+
+		Scopes []string `json:"scopes"`
+
+		// alert status filter (default: active)
+		Statuses []string `json:"statuses"`
+	}
+)
+
+// IsStatusEnabled returns true if alerts with status should be exported
+func (c *CollectorCostAlerts) IsStatusEnabled(status string) bool {
+	statusList := c.Statuses
+	if len(statusList) == 0 {
+		statusList = []string{"active"}
+	}
+
+	for _, val := range statusList {
+		if strings.EqualFold(val, status) {
+			return true
+		}
+	}
+
+	return false
+}
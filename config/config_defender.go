@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type (
+	CollectorDefender struct {
+		*CollectorBase `yaml:",inline"`
+
+		// advisor recommendations (shared with advisor collector)
+		Advisor struct {
+			// recommendation categories (eg. Security), defaults to all categories
+			Categories []string `json:"categories"`
+
+			ProblemMaxLength int `json:"problemMaxLength"`
+		} `json:"advisor"`
+
+		// security assessments per resource (unhealthy resources)
+		Assessments struct {
+			Enabled bool `json:"enabled"`
+
+			// High, Medium or Low, defaults to all severities
+			Severities []string `json:"severities"`
+
+			// assessment names or display names, defaults to all assessments
+			Assessments []string `json:"assessments"`
+		} `json:"assessments"`
+
+		// defender plans (pricing tiers) of subscriptions
+		Plans struct {
+			Enabled bool `json:"enabled"`
+
+			// plans which must be enabled (standard tier) on every subscription (eg. VirtualMachines, StorageAccounts, KeyVaults, Containers)
+			Required []string `json:"required"`
+		} `json:"plans"`
+
+		// regulatory compliance (standards, controls and assessments)
+		RegulatoryCompliance struct {
+			Enabled bool `json:"enabled"`
+
+			// standard names (eg. Microsoft-cloud-security-benchmark, ISO-27001, PCI-DSS-4), defaults to all standards
+			Standards []string `json:"standards"`
+
+			// passed/failed/skipped resources per assessment
+			// one request per control, standard and subscription, limit the standards when enabling it
+			Assessments bool `json:"assessments"`
+		} `json:"regulatoryCompliance"`
+	}
+)
+
+// IsRegulatoryComplianceStandardEnabled checks if regulatory compliance standard should be collected
+func (c *CollectorDefender) IsRegulatoryComplianceStandardEnabled(standard string) bool {
+	if len(c.RegulatoryCompliance.Standards) == 0 {
+		return true
+	}
+
+	for _, val := range c.RegulatoryCompliance.Standards {
+		if strings.EqualFold(val, standard) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAssessmentSeverityEnabled checks if assessments of severity should be exported as metric
+func (c *CollectorDefender) IsAssessmentSeverityEnabled(severity string) bool {
+	if len(c.Assessments.Severities) == 0 {
+		return true
+	}
+
+	for _, val := range c.Assessments.Severities {
+		if strings.EqualFold(val, severity) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAssessmentEnabled checks if assessment (by name or display name) should be exported as metric
+func (c *CollectorDefender) IsAssessmentEnabled(name, displayName string) bool {
+	if len(c.Assessments.Assessments) == 0 {
+		return true
+	}
+
+	for _, val := range c.Assessments.Assessments {
+		if strings.EqualFold(val, name) || strings.EqualFold(val, displayName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CollectorDefender) Validate() []error {
+	var errList []error
+
+	for _, severity := range c.Assessments.Severities {
+		switch strings.ToLower(severity) {
+		case "high", "medium", "low":
+		default:
+			errList = append(errList, fmt.Errorf(`defender: assessment severity "%v" is not supported`, severity))
+		}
+	}
+
+	return errList
+}
+
+type (
+	CollectorDefenderAlerts struct {
+		*CollectorBase `yaml:",inline"`
+
+		// look-back window for alerts (based on alert start time), defaults to 7 days
+		LookBack *time.Duration `json:"lookBack"`
+
+		// High, Medium, Low or Informational, defaults to all severities
+		Severities []string `json:"severities"`
+	}
+)
+
+func (c *CollectorDefenderAlerts) GetLookBack() time.Duration {
+	if c.LookBack != nil {
+		return *c.LookBack
+	}
+	return 7 * 24 * time.Hour
+}
+
+// IsSeverityEnabled checks if alerts of severity should be exported as metric
+func (c *CollectorDefenderAlerts) IsSeverityEnabled(severity string) bool {
+	if len(c.Severities) == 0 {
+		return true
+	}
+
+	for _, val := range c.Severities {
+		if strings.EqualFold(val, severity) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CollectorDefenderAlerts) Validate() []error {
+	var errList []error
+
+	for _, severity := range c.Severities {
+		switch strings.ToLower(severity) {
+		case "high", "medium", "low", "informational":
+		default:
+			errList = append(errList, fmt.Errorf(`defenderAlerts: severity "%v" is not supported`, severity))
+		}
+	}
+
+	if c.LookBack != nil && c.LookBack.Seconds() <= 0 {
+		errList = append(errList, fmt.Errorf(`defenderAlerts: lookBack "%v" must be positive`, c.LookBack.String()))
+	}
+
+	return errList
+}
@@ -0,0 +1,68 @@
+package config
+
+type (
+	CollectorPurchaseRecommendation struct {
+		*CollectorBase `yaml:",inline"`
+
+		// scopes for recommendations, defaults to all subscriptions
+		Scopes []string `json:"scopes"`
+
+		// Last7Days, Last30Days or Last60Days
+		LookBackPeriods []string `json:"lookBackPeriods"`
+
+		// reservation recommendations
+		Reservation struct {
+			Enabled *bool `json:"enabled"`
+
+			// VirtualMachines, SQLDatabases, PostgreSQL, ManagedDisk, MySQL, RedHat, MariaDB, RedisCache, CosmosDB, ...
+			ResourceTypes []string `json:"resourceTypes"`
+
+			// Single or Shared
+			ScopeTypes []string `json:"scopeTypes"`
+		} `json:"reservation"`
+
+		// savings plan (benefit) recommendations
+		SavingsPlan struct {
+			Enabled *bool `json:"enabled"`
+
+			// P1Y or P3Y
+			Terms []string `json:"terms"`
+		} `json:"savingsPlan"`
+	}
+)
+
+func (c *CollectorPurchaseRecommendation) GetLookBackPeriods() []string {
+	if len(c.LookBackPeriods) > 0 {
+		return c.LookBackPeriods
+	}
+	return []string{"Last30Days"}
+}
+
+func (c *CollectorPurchaseRecommendation) IsReservationEnabled() bool {
+	return c.Reservation.Enabled == nil || *c.Reservation.Enabled
+}
+
+func (c *CollectorPurchaseRecommendation) GetReservationResourceTypes() []string {
+	if len(c.Reservation.ResourceTypes) > 0 {
+		return c.Reservation.ResourceTypes
+	}
+	return []string{"VirtualMachines"}
+}
+
+func (c *CollectorPurchaseRecommendation) GetReservationScopeTypes() []string {
+	if len(c.Reservation.ScopeTypes) > 0 {
+		return c.Reservation.ScopeTypes
+	}
+	return []string{"Single", "Shared"}
+}
+
+func (c *CollectorPurchaseRecommendation) IsSavingsPlanEnabled() bool {
+	return c.SavingsPlan.Enabled == nil || *c.SavingsPlan.Enabled
+}
+
+func (c *CollectorPurchaseRecommendation) GetSavingsPlanTerms() []string {
+	if len(c.SavingsPlan.Terms) > 0 {
+		return c.SavingsPlan.Terms
+	}
+	return []string{"P1Y", "P3Y"}
+}
@@ -1,5 +1,15 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	CollectorReservationModeHistory = "history"
+	CollectorReservationModeLatest  = "latest"
+)
+
 type (
 	CollectorReservation struct {
 		*CollectorBase `yaml:",inline"`
@@ -7,5 +17,57 @@ type (
 		Scopes      []string `json:"scopes"`
 		Granularity string   `json:"granularity"`
 		FromDays    int      `json:"fromDays"`
+
+		// history (one series per usageDate) or latest (only latest complete grain, without usageDate label)
+		Mode string `json:"mode"`
+
+		// rolling averages of utilization (windows in days)
+		AverageDays []int `json:"averageDays"`
+
+		// reservation details (utilization per resource)
+		Details bool `json:"details"`
+
+		// reservation inventory (expiry, renewal and provisioning state of all reservations)
+		Inventory bool `json:"inventory"`
 	}
 )
+
+func (c *CollectorReservation) GetMode() string {
+	if c.Mode != "" {
+		return strings.ToLower(c.Mode)
+	}
+	return CollectorReservationModeHistory
+}
+
+func (c *CollectorReservation) IsLatestMode() bool {
+	return c.GetMode() == CollectorReservationModeLatest
+}
+
+// GetFetchDays returns the number of days which needs to be fetched (fromDays or largest average window)
+func (c *CollectorReservation) GetFetchDays() int {
+	days := c.FromDays
+	for _, averageDays := range c.AverageDays {
+		if averageDays > days {
+			days = averageDays
+		}
+	}
+	return days
+}
+
+func (c *CollectorReservation) Validate() []error {
+	var errList []error
+
+	switch c.GetMode() {
+	case CollectorReservationModeHistory, CollectorReservationModeLatest:
+	default:
+		errList = append(errList, fmt.Errorf(`reservation: mode "%v" is not supported`, c.Mode))
+	}
+
+	for _, averageDays := range c.AverageDays {
+		if averageDays <= 0 {
+			errList = append(errList, fmt.Errorf(`reservation: averageDays "%v" must be positive`, averageDays))
+		}
+	}
+
+	return errList
+}
@@ -0,0 +1,26 @@
+package config
+
+type (
+	CollectorSavingsPlan struct {
+		*CollectorBase `yaml:",inline"`
+
+		// billing account or billing profile scopes
+		Scopes      []string `json:"scopes"`
+		Granularity string   `json:"granularity"`
+		FromDays    int      `json:"fromDays"`
+	}
+)
+
+func (c *CollectorSavingsPlan) GetGranularity() string {
+	if c.Granularity != "" {
+		return c.Granularity
+	}
+	return "daily"
+}
+
+func (c *CollectorSavingsPlan) GetFromDays() int {
+	if c.FromDays > 0 {
+		return c.FromDays
+	}
+	return 30
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"strings"
+)
+
+type (
+	CollectorServiceHealth struct {
+		*CollectorBase `yaml:",inline"`
+
+		// ServiceIssue, PlannedMaintenance, HealthAdvisory, SecurityAdvisory, EmergingIssues or RCA, defaults to all event types
+		EventTypes []string `json:"eventTypes"`
+
+		TitleMaxLength int `json:"titleMaxLength"`
+	}
+)
+
+// IsEventTypeEnabled checks if events of event type should be exported as metric
+func (c *CollectorServiceHealth) IsEventTypeEnabled(eventType string) bool {
+	if len(c.EventTypes) == 0 {
+		return true
+	}
+
+	for _, val := range c.EventTypes {
+		if strings.EqualFold(val, eventType) {
+			return true
+		}
+	}
+	return false
+}
@@ -8,6 +8,7 @@ import (
 
 var (
 	prometheusLabelReplacerRegExp = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	prometheusLabelNameRegExp     = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 )
 
 func lowerFirst(s string) string {
@@ -118,6 +118,21 @@ func initConfig() {
 	if err != nil {
 		logger.Fatal(err.Error())
 	}
+
+	if err := Config.Collectors.Costs.LoadQueriesFromPaths(); err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	if err := Config.Collectors.Costs.LoadQueriesFromEnv(CostsQueryEnvVarPrefix); err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	if errList := Config.Validate(); len(errList) > 0 {
+		for _, err := range errList {
+			logger.Error(err.Error())
+		}
+		logger.Fatal(`invalid configuration`)
+	}
 }
 
 func initAzureConnection() {
@@ -264,6 +279,57 @@ func initMetricCollector() {
 		logger.With(slog.String("collector", collectorName)).Infof("collector disabled")
 	}
 
+	collectorName = "savingsPlan"
+	if Config.Collectors.SavingsPlan.IsEnabled() {
+		c := collector.New(collectorName, &MetricsCollectorAzureRmSavingsPlan{}, logger.Slog())
+		c.SetScapeTime(*Config.Collectors.SavingsPlan.ScrapeTime)
+		if err := c.SetCache(
+			Opts.GetCachePath(collectorName+".json"),
+			collector.BuildCacheTag(cacheTag, Config.Azure, Config.Collectors.SavingsPlan),
+		); err != nil {
+			logger.Fatal(err.Error())
+		}
+		if err := c.Start(); err != nil {
+			logger.Fatal(err.Error())
+		}
+	} else {
+		logger.With(slog.String("collector", collectorName)).Infof("collector disabled")
+	}
+
+	collectorName = "billing"
+	if Config.Collectors.Billing.IsEnabled() {
+		c := collector.New(collectorName, &MetricsCollectorAzureRmBilling{}, logger.Slog())
+		c.SetScapeTime(*Config.Collectors.Billing.ScrapeTime)
+		if err := c.SetCache(
+			Opts.GetCachePath(collectorName+".json"),
+			collector.BuildCacheTag(cacheTag, Config.Azure, Config.Collectors.Billing),
+		); err != nil {
+			logger.Fatal(err.Error())
+		}
+		if err := c.Start(); err != nil {
+			logger.Fatal(err.Error())
+		}
+	} else {
+		logger.With(slog.String("collector", collectorName)).Infof("collector disabled")
+	}
+
+	collectorName = "purchaseRecommendation"
+	if Config.Collectors.PurchaseRecommendation.IsEnabled() {
+		c := collector.New(collectorName, &MetricsCollectorAzureRmPurchaseRecommendation{}, logger.Slog())
+		c.SetScapeTime(*Config.Collectors.PurchaseRecommendation.ScrapeTime)
+		if err := c.SetCache(
+			Opts.GetCachePath(collectorName+".json"),
+			collector.BuildCacheTag(cacheTag, Config.Azure, Config.Collectors.PurchaseRecommendation),
+		); err != nil {
+			logger.Fatal(err.Error())
+		}
+		if err := c.Start(); err != nil {
+			logger.Fatal(err.Error())
+		}
+	} else {
+		logger.With(slog.String("collector", collectorName)).Infof("collector disabled")
+	}
+
 	collectorName = "budgets"
 	if Config.Collectors.Budgets.IsEnabled() {
 		c := collector.New(collectorName, &MetricsCollectorAzureRmBudgets{}, logger.Slog())
@@ -281,6 +347,23 @@ func initMetricCollector() {
 		logger.With(slog.String("collector", collectorName)).Infof("collector disabled")
 	}
 
+	collectorName = "costAlerts"
+	if Config.Collectors.CostAlerts.IsEnabled() {
+		c := collector.New(collectorName, &MetricsCollectorAzureRmCostAlerts{}, logger.Slog())
+		c.SetScapeTime(*Config.Collectors.CostAlerts.ScrapeTime)
+		if err := c.SetCache(
+			Opts.GetCachePath(collectorName+".json"),
+			collector.BuildCacheTag(cacheTag, Config.Azure, Config.Collectors.CostAlerts),
+		); err != nil {
+			logger.Fatal(err.Error())
+		}
+		if err := c.Start(); err != nil {
+			logger.Fatal(err.Error())
+		}
+	} else {
+		logger.With(slog.String("collector", collectorName)).Infof("collector disabled")
+	}
+
 	collectorName = "advisor"
 	if Config.Collectors.Advisor.IsEnabled() {
 		c := collector.New(collectorName, &MetricsCollectorAzureRmAdvisor{}, logger.Slog())
@@ -315,6 +398,23 @@ func initMetricCollector() {
 		logger.With(slog.String("collector", collectorName)).Infof("collector disabled")
 	}
 
+	collectorName = "defenderAlerts"
+	if Config.Collectors.DefenderAlerts.IsEnabled() {
+		c := collector.New(collectorName, &MetricsCollectorAzureRmDefenderAlerts{}, logger.Slog())
+		c.SetScapeTime(*Config.Collectors.DefenderAlerts.ScrapeTime)
+		if err := c.SetCache(
+			Opts.GetCachePath(collectorName+".json"),
+			collector.BuildCacheTag(cacheTag, Config.Azure, Config.Collectors.DefenderAlerts),
+		); err != nil {
+			logger.Fatal(err.Error())
+		}
+		if err := c.Start(); err != nil {
+			logger.Fatal(err.Error())
+		}
+	} else {
+		logger.With(slog.String("collector", collectorName)).Infof("collector disabled")
+	}
+
 	collectorName = "resourceHealth"
 	if Config.Collectors.ResourceHealth.IsEnabled() {
 		c := collector.New(collectorName, &MetricsCollectorAzureRmHealth{}, logger.Slog())
@@ -332,6 +432,23 @@ func initMetricCollector() {
 		logger.With(slog.String("collector", collectorName)).Infof("collector disabled")
 	}
 
+	collectorName = "serviceHealth"
+	if Config.Collectors.ServiceHealth.IsEnabled() {
+		c := collector.New(collectorName, &MetricsCollectorAzureRmServiceHealth{}, logger.Slog())
+		c.SetScapeTime(*Config.Collectors.ServiceHealth.ScrapeTime)
+		if err := c.SetCache(
+			Opts.GetCachePath(collectorName+".json"),
+			collector.BuildCacheTag(cacheTag, Config.Azure, Config.Collectors.ServiceHealth),
+		); err != nil {
+			logger.Fatal(err.Error())
+		}
+		if err := c.Start(); err != nil {
+			logger.Fatal(err.Error())
+		}
+	} else {
+		logger.With(slog.String("collector", collectorName)).Infof("collector disabled")
+	}
+
 	collectorName = "iam"
 	if Config.Collectors.Iam.IsEnabled() {
 		initMsGraphConnection()
@@ -2,7 +2,10 @@ package main
 
 import (
 	"log/slog"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/advisor/armadvisor"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
@@ -14,39 +17,218 @@ import (
 type MetricsCollectorAzureRmAdvisor struct {
 	collector.Processor
 
+	// recommendation lifecycle (first seen is restored from cached metrics) and regeneration state
+	lifecycle struct {
+		lock         sync.Mutex
+		firstSeen    map[string]float64
+		lastGenerate map[string]time.Time
+	}
+
 	prometheus struct {
-		advisorRecommendation *prometheus.GaugeVec
+		advisorRecommendation            *prometheus.GaugeVec
+		advisorRecommendationSavings     *prometheus.GaugeVec
+		advisorRecommendationFirstSeen   *prometheus.GaugeVec
+		advisorRecommendationLastUpdated *prometheus.GaugeVec
+		advisorRecommendationSuppressed  *prometheus.GaugeVec
+		advisorSuppressionExpiry         *prometheus.GaugeVec
+
+		advisorScore                  *prometheus.GaugeVec
+		advisorScorePotentialIncrease *prometheus.GaugeVec
+		advisorScoreImpactedResources *prometheus.GaugeVec
+		advisorScoreConsumptionUnits  *prometheus.GaugeVec
+		advisorScoreHistory           *prometheus.GaugeVec
 	}
 }
 
 func (m *MetricsCollectorAzureRmAdvisor) Setup(collector *collector.Collector) {
 	m.Processor.Setup(collector)
 
+	recommendationLabels := []string{
+		"recommendationID",
+		"resourceID",
+		"resourceType",
+		"category",
+		"impact",
+		"risk",
+		"recommendationSubCategory",
+		"problem",
+		"solution",
+	}
+
+	if Config.Collectors.Advisor.Labels.RecommendedSku {
+		recommendationLabels = append(recommendationLabels, "recommendedSku")
+	}
+
+	if Config.Collectors.Advisor.Labels.Term {
+		recommendationLabels = append(recommendationLabels, "term")
+	}
+
 	m.prometheus.advisorRecommendation = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_advisor_recommendation",
 			Help: "Azure Advisor recommendation",
 		},
+		AzureResourceTagManager.AddToPrometheusLabels(recommendationLabels),
+	)
+	m.Collector.RegisterMetricList("advisorRecommendation", m.prometheus.advisorRecommendation, true)
+
+	m.prometheus.advisorRecommendationSavings = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_advisor_recommendation_savings",
+			Help: "Azure Advisor recommendation estimated savings",
+		},
 		[]string{
 			"recommendationID",
 			"resourceID",
-			"resourceType",
 			"category",
-			"impact",
-			"risk",
-			"recommendationSubCategory",
-			"problem",
-			"solution",
+			"period",
+			"currency",
 		},
 	)
-	m.Collector.RegisterMetricList("advisorRecommendation", m.prometheus.advisorRecommendation, true)
+	m.Collector.RegisterMetricList("advisorRecommendationSavings", m.prometheus.advisorRecommendationSavings, true)
+
+	// ----------------------------------------------------
+	// Recommendation lifecycle
+	m.lifecycle.firstSeen = map[string]float64{}
+	m.lifecycle.lastGenerate = map[string]time.Time{}
+
+	m.prometheus.advisorRecommendationFirstSeen = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_advisor_recommendation_first_seen_timestamp_seconds",
+			Help: "Azure Advisor recommendation timestamp when recommendation was seen first by exporter",
+		},
+		[]string{
+			"recommendationID",
+			"resourceID",
+			"category",
+		},
+	)
+	m.Collector.RegisterMetricList("advisorRecommendationFirstSeen", m.prometheus.advisorRecommendationFirstSeen, true)
+
+	m.prometheus.advisorRecommendationLastUpdated = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_advisor_recommendation_last_updated_timestamp_seconds",
+			Help: "Azure Advisor recommendation last updated timestamp",
+		},
+		[]string{
+			"recommendationID",
+			"resourceID",
+			"category",
+		},
+	)
+	m.Collector.RegisterMetricList("advisorRecommendationLastUpdated", m.prometheus.advisorRecommendationLastUpdated, true)
+
+	// ----------------------------------------------------
+	// Suppressions
+	m.prometheus.advisorRecommendationSuppressed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_advisor_recommendation_suppressed",
+			Help: "Azure Advisor suppressed recommendation",
+		},
+		[]string{
+			"subscriptionID",
+			"recommendationID",
+			"resourceID",
+			"suppressionName",
+		},
+	)
+	m.Collector.RegisterMetricList("advisorRecommendationSuppressed", m.prometheus.advisorRecommendationSuppressed, true)
+
+	m.prometheus.advisorSuppressionExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_advisor_suppression_expiry_timestamp_seconds",
+			Help: "Azure Advisor suppression expiration timestamp",
+		},
+		[]string{
+			"subscriptionID",
+			"recommendationID",
+			"resourceID",
+			"suppressionName",
+		},
+	)
+	m.Collector.RegisterMetricList("advisorSuppressionExpiry", m.prometheus.advisorSuppressionExpiry, true)
+
+	// ----------------------------------------------------
+	// Advisor score
+	m.prometheus.advisorScore = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_advisor_score_percentage",
+			Help: "Azure Advisor score in percent (overall and per category)",
+		},
+		[]string{
+			"subscriptionID",
+			"category",
+		},
+	)
+	m.Collector.RegisterMetricList("advisorScore", m.prometheus.advisorScore, true)
+
+	m.prometheus.advisorScorePotentialIncrease = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_advisor_score_potential_increase",
+			Help: "Azure Advisor score potential increase by implementing all recommendations",
+		},
+		[]string{
+			"subscriptionID",
+			"category",
+		},
+	)
+	m.Collector.RegisterMetricList("advisorScorePotentialIncrease", m.prometheus.advisorScorePotentialIncrease, true)
+
+	m.prometheus.advisorScoreImpactedResources = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_advisor_score_impacted_resources",
+			Help: "Azure Advisor score count of impacted resources",
+		},
+		[]string{
+			"subscriptionID",
+			"category",
+		},
+	)
+	m.Collector.RegisterMetricList("advisorScoreImpactedResources", m.prometheus.advisorScoreImpactedResources, true)
+
+	m.prometheus.advisorScoreConsumptionUnits = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_advisor_score_consumption_units",
+			Help: "Azure Advisor score consumption units (weight of category)",
+		},
+		[]string{
+			"subscriptionID",
+			"category",
+		},
+	)
+	m.Collector.RegisterMetricList("advisorScoreConsumptionUnits", m.prometheus.advisorScoreConsumptionUnits, true)
+
+	m.prometheus.advisorScoreHistory = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_advisor_score_history_percentage",
+			Help: "Azure Advisor score history in percent",
+		},
+		[]string{
+			"subscriptionID",
+			"category",
+			"aggregationLevel",
+			"date",
+		},
+	)
+	m.Collector.RegisterMetricList("advisorScoreHistory", m.prometheus.advisorScoreHistory, true)
 }
 
-func (m *MetricsCollectorAzureRmAdvisor) Reset() {}
+func (m *MetricsCollectorAzureRmAdvisor) Reset() {
+	// called after collect run and after cache restore, metric lists contain the current state
+	m.restoreAdvisorRecommendationFirstSeen()
+}
 
 func (m *MetricsCollectorAzureRmAdvisor) Collect(callback chan<- func()) {
 	err := AzureSubscriptionsIterator.ForEachAsync(m.Logger(), func(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
 		m.collectAzureAdvisorRecommendations(subscription, logger)
+
+		if Config.Collectors.Advisor.Suppressions.Enabled {
+			m.collectAzureAdvisorSuppressions(subscription, logger)
+		}
+
+		if Config.Collectors.Advisor.Score.Enabled {
+			m.collectAzureAdvisorScore(subscription, logger)
+		}
 	})
 	if err != nil {
 		panic(err)
@@ -54,87 +236,143 @@ func (m *MetricsCollectorAzureRmAdvisor) Collect(callback chan<- func()) {
 }
 
 func (m *MetricsCollectorAzureRmAdvisor) collectAzureAdvisorRecommendations(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
-	client, err := armadvisor.NewRecommendationsClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
-	if err != nil {
-		panic(err)
-	}
-
 	// Generate recommendations first (async operation)
 	// Note: This is a fire-and-forget operation. The generation is asynchronous,
 	// so newly generated recommendations will be available in the next scrape cycle.
-	_, err = client.Generate(m.Context(), nil)
-	if err != nil {
-		logger.Warn("failed to generate recommendations for subscription", slog.String("subscriptionID", to.StringLower(subscription.SubscriptionID)), slog.Any("error", err))
+	if m.shouldGenerateAdvisorRecommendations(to.StringLower(subscription.SubscriptionID)) {
+		client, err := armadvisor.NewRecommendationsClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+		if err != nil {
+			panic(err)
+		}
+
+		_, err = client.Generate(m.Context(), nil)
+		if err != nil {
+			logger.Warn("failed to generate recommendations for subscription", slog.String("subscriptionID", to.StringLower(subscription.SubscriptionID)), slog.Any("error", err))
+		}
 	}
 
 	recommendationMetrics := m.Collector.GetMetricList("advisorRecommendation")
+	savingsMetrics := m.Collector.GetMetricList("advisorRecommendationSavings")
+	firstSeenMetrics := m.Collector.GetMetricList("advisorRecommendationFirstSeen")
+	lastUpdatedMetrics := m.Collector.GetMetricList("advisorRecommendationLastUpdated")
 
-	pager := client.NewListPager(nil)
-	for pager.More() {
-		result, err := pager.NextPage(m.Context())
-		if err != nil {
-			panic(err)
+	recommendations := azureAdvisorRecommendations.Fetch(m.Context(), subscription)
+	recommendations = filterAdvisorRecommendationsByCategory(recommendations, Config.Collectors.Advisor.Categories)
+
+	for _, recommendation := range recommendations {
+		if recommendation.Properties == nil {
+			continue
 		}
 
-		for _, recommendation := range result.Value {
-			category := ""
-			if recommendation.Properties.Category != nil {
-				category = strings.ToLower(string(*recommendation.Properties.Category))
-			}
+		category := advisorRecommendationCategory(recommendation)
 
-			risk := ""
-			if recommendation.Properties.Risk != nil {
-				risk = strings.ToLower(string(*recommendation.Properties.Risk))
-			}
+		risk := ""
+		if recommendation.Properties.Risk != nil {
+			risk = strings.ToLower(string(*recommendation.Properties.Risk))
+		}
 
-			impact := ""
-			if recommendation.Properties.Impact != nil {
-				impact = strings.ToLower(string(*recommendation.Properties.Impact))
-			}
+		impact := ""
+		if recommendation.Properties.Impact != nil {
+			impact = strings.ToLower(string(*recommendation.Properties.Impact))
+		}
 
-			problem := ""
-			solution := ""
-			if recommendation.Properties.ShortDescription != nil {
-				problem = to.String(recommendation.Properties.ShortDescription.Problem)
-				solution = to.String(recommendation.Properties.ShortDescription.Solution)
-			}
+		problem := ""
+		solution := ""
+		if recommendation.Properties.ShortDescription != nil {
+			problem = to.String(recommendation.Properties.ShortDescription.Problem)
+			solution = to.String(recommendation.Properties.ShortDescription.Solution)
+		}
 
-			// Truncate problem and solution if configured
-			if Config.Collectors.Advisor.ProblemMaxLength > 0 {
-				problem = truncateStrings(problem, Config.Collectors.Advisor.ProblemMaxLength, "...")
-			}
-			if Config.Collectors.Advisor.SolutionMaxLength > 0 {
-				solution = truncateStrings(solution, Config.Collectors.Advisor.SolutionMaxLength, "...")
-			}
+		// Truncate problem and solution if configured
+		if Config.Collectors.Advisor.ProblemMaxLength > 0 {
+			problem = truncateStrings(problem, Config.Collectors.Advisor.ProblemMaxLength, "...")
+		}
+		if Config.Collectors.Advisor.SolutionMaxLength > 0 {
+			solution = truncateStrings(solution, Config.Collectors.Advisor.SolutionMaxLength, "...")
+		}
 
-			recommendationSubCategory := ""
-			if recommendation.Properties.ExtendedProperties != nil {
-				if subCat, ok := recommendation.Properties.ExtendedProperties["recommendationSubCategory"]; ok && subCat != nil {
-					recommendationSubCategory = to.String(subCat)
-				}
+		recommendationSubCategory := ""
+		if recommendation.Properties.ExtendedProperties != nil {
+			if subCat, ok := recommendation.Properties.ExtendedProperties["recommendationSubCategory"]; ok && subCat != nil {
+				recommendationSubCategory = to.String(subCat)
 			}
+		}
+
+		resourceID := ""
+		if recommendation.Properties.ResourceMetadata != nil && recommendation.Properties.ResourceMetadata.ResourceID != nil {
+			resourceID = to.String(recommendation.Properties.ResourceMetadata.ResourceID)
+		}
 
-			resourceID := ""
-			if recommendation.Properties.ResourceMetadata != nil && recommendation.Properties.ResourceMetadata.ResourceID != nil {
-				resourceID = to.String(recommendation.Properties.ResourceMetadata.ResourceID)
+		resourceType := to.StringLower(recommendation.Properties.ImpactedField)
+
+		recommendationID := to.StringLower(recommendation.Name)
+
+		infoLabels := prometheus.Labels{
+			"recommendationID":          recommendationID,
+			"resourceID":                resourceID,
+			"resourceType":              resourceType,
+			"category":                  category,
+			"impact":                    impact,
+			"risk":                      risk,
+			"recommendationSubCategory": recommendationSubCategory,
+			"problem":                   problem,
+			"solution":                  solution,
+		}
+
+		if Config.Collectors.Advisor.Labels.RecommendedSku {
+			infoLabels["recommendedSku"] = advisorExtendedProperty(recommendation.Properties.ExtendedProperties, "targetSku", "recommendedSku", "displaySKU", "sku")
+		}
+
+		if Config.Collectors.Advisor.Labels.Term {
+			infoLabels["term"] = advisorExtendedProperty(recommendation.Properties.ExtendedProperties, "term")
+		}
+
+		infoLabels = AzureResourceTagManager.AddResourceTagsToPrometheusLabels(m.Context(), infoLabels, resourceID)
+		recommendationMetrics.Add(infoLabels, 1)
+
+		lifecycleLabels := prometheus.Labels{
+			"recommendationID": recommendationID,
+			"resourceID":       resourceID,
+			"category":         category,
+		}
+		firstSeenMetrics.Add(lifecycleLabels, m.getAdvisorRecommendationFirstSeen(recommendationID))
+		if recommendation.Properties.LastUpdated != nil {
+			lastUpdatedMetrics.AddTime(lifecycleLabels, *recommendation.Properties.LastUpdated)
+		}
+
+		// estimated savings of cost recommendations
+		savingsCurrency := advisorExtendedProperty(recommendation.Properties.ExtendedProperties, "savingsCurrency")
+		for period, propertyName := range map[string]string{"monthly": "savingsAmount", "annual": "annualSavingsAmount"} {
+			value := advisorExtendedProperty(recommendation.Properties.ExtendedProperties, propertyName)
+			if value == "" {
+				continue
 			}
 
-			resourceType := to.StringLower(recommendation.Properties.ImpactedField)
+			savings, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				logger.Warn(`unable to parse advisor recommendation savings`, slog.String("recommendationID", recommendationID), slog.String("property", propertyName), slog.Any("error", err))
+				continue
+			}
 
-			recommendationID := to.StringLower(recommendation.Name)
+			savingsMetrics.Add(prometheus.Labels{
+				"recommendationID": recommendationID,
+				"resourceID":       resourceID,
+				"category":         category,
+				"period":           period,
+				"currency":         savingsCurrency,
+			}, savings)
+		}
+	}
+}
 
-			infoLabels := prometheus.Labels{
-				"recommendationID":          recommendationID,
-				"resourceID":                resourceID,
-				"resourceType":              resourceType,
-				"category":                  category,
-				"impact":                    impact,
-				"risk":                      risk,
-				"recommendationSubCategory": recommendationSubCategory,
-				"problem":                   problem,
-				"solution":                  solution,
+// advisorExtendedProperty returns the first non-empty value of the extended properties (names are case-insensitive)
+func advisorExtendedProperty(extendedProperties map[string]*string, names ...string) string {
+	for _, name := range names {
+		for key, value := range extendedProperties {
+			if strings.EqualFold(key, name) && to.String(value) != "" {
+				return to.String(value)
 			}
-			recommendationMetrics.Add(infoLabels, 1)
 		}
 	}
+	return ""
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/advisor/armadvisor"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/prometheus/client_golang/prometheus"
+	prometheusCommon "github.com/webdevops/go-common/prometheus"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+// restoreAdvisorRecommendationFirstSeen merges the first seen metrics into the first seen state,
+// the metrics are also persisted and restored by the collector cache.
+// recommendations missing in the metrics (eg. partial run) keep their state, the older timestamp wins
+func (m *MetricsCollectorAzureRmAdvisor) restoreAdvisorRecommendationFirstSeen() {
+	metricList := m.Collector.GetMetricList("advisorRecommendationFirstSeen")
+	if metricList == nil {
+		return
+	}
+
+	m.lifecycle.lock.Lock()
+	defer m.lifecycle.lock.Unlock()
+	mergeAdvisorRecommendationFirstSeen(m.lifecycle.firstSeen, metricList.GetList())
+}
+
+// mergeAdvisorRecommendationFirstSeen adds the first seen rows to the state and keeps the older timestamp
+func mergeAdvisorRecommendationFirstSeen(firstSeen map[string]float64, rows []prometheusCommon.MetricRow) {
+	for _, row := range rows {
+		recommendationID := row.Labels["recommendationID"]
+		if existing, exists := firstSeen[recommendationID]; !exists || row.Value < existing {
+			firstSeen[recommendationID] = row.Value
+		}
+	}
+}
+
+// getAdvisorRecommendationFirstSeen returns the first seen timestamp of the recommendation (now for new recommendations)
+func (m *MetricsCollectorAzureRmAdvisor) getAdvisorRecommendationFirstSeen(recommendationID string) float64 {
+	m.lifecycle.lock.Lock()
+	defer m.lifecycle.lock.Unlock()
+
+	if firstSeen, exists := m.lifecycle.firstSeen[recommendationID]; exists {
+		return firstSeen
+	}
+	return float64(time.Now().Unix())
+}
+
+// shouldGenerateAdvisorRecommendations returns true if the recommendations of the subscription should be regenerated
+func (m *MetricsCollectorAzureRmAdvisor) shouldGenerateAdvisorRecommendations(subscriptionID string) bool {
+	if !Config.Collectors.Advisor.Generate.Enabled {
+		return false
+	}
+
+	m.lifecycle.lock.Lock()
+	defer m.lifecycle.lock.Unlock()
+
+	if lastGenerate, exists := m.lifecycle.lastGenerate[subscriptionID]; exists && time.Since(lastGenerate) < Config.Collectors.Advisor.GetGenerateInterval() {
+		return false
+	}
+
+	m.lifecycle.lastGenerate[subscriptionID] = time.Now()
+	return true
+}
+
+// collectAzureAdvisorSuppressions collects the suppressed (snoozed or dismissed) recommendations
+func (m *MetricsCollectorAzureRmAdvisor) collectAzureAdvisorSuppressions(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
+	client, err := armadvisor.NewSuppressionsClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	suppressedMetrics := m.Collector.GetMetricList("advisorRecommendationSuppressed")
+	expiryMetrics := m.Collector.GetMetricList("advisorSuppressionExpiry")
+
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			panic(err)
+		}
+
+		for _, suppression := range result.Value {
+			// {resourceUri}/providers/Microsoft.Advisor/recommendations/{recommendationId}/suppressions/{name}
+			suppressionId := to.String(suppression.ID)
+			resourceID, recommendationID := "", ""
+			if i := strings.Index(strings.ToLower(suppressionId), "/providers/microsoft.advisor/recommendations/"); i >= 0 {
+				resourceID = suppressionId[:i]
+				if parts := strings.Split(suppressionId[i+1:], "/"); len(parts) >= 4 {
+					recommendationID = strings.ToLower(parts[3])
+				}
+			}
+
+			labels := prometheus.Labels{
+				"subscriptionID":   to.StringLower(subscription.SubscriptionID),
+				"recommendationID": recommendationID,
+				"resourceID":       resourceID,
+				"suppressionName":  to.String(suppression.Name),
+			}
+
+			suppressedMetrics.Add(labels, 1)
+
+			if suppression.Properties != nil && suppression.Properties.ExpirationTimeStamp != nil {
+				expiryMetrics.AddTime(labels, *suppression.Properties.ExpirationTimeStamp)
+			}
+		}
+	}
+
+	logger.Debug(`collected advisor suppressions`)
+}
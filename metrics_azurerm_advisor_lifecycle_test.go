@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	prometheusCommon "github.com/webdevops/go-common/prometheus"
+)
+
+func TestMergeAdvisorRecommendationFirstSeen(t *testing.T) {
+	firstSeen := map[string]float64{
+		"kept":  100,
+		"older": 200,
+		"newer": 300,
+	}
+
+	// partial run, "kept" is missing
+	mergeAdvisorRecommendationFirstSeen(firstSeen, []prometheusCommon.MetricRow{
+		{Labels: prometheus.Labels{"recommendationID": "older"}, Value: 150},
+		{Labels: prometheus.Labels{"recommendationID": "newer"}, Value: 400},
+		{Labels: prometheus.Labels{"recommendationID": "new"}, Value: 500},
+	})
+
+	expected := map[string]float64{
+		"kept":  100,
+		"older": 150,
+		"newer": 300,
+		"new":   500,
+	}
+	for recommendationID, value := range expected {
+		if firstSeen[recommendationID] != value {
+			t.Errorf("expected first seen %v for %v, got %v", value, recommendationID, firstSeen[recommendationID])
+		}
+	}
+}
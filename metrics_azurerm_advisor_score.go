@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+const (
+	AdvisorScoreApiVersion = "2023-01-01"
+)
+
+type (
+	// advisorScoreList is the response of the advisor score api (not available in armadvisor v1)
+	advisorScoreList struct {
+		Value []advisorScoreEntity `json:"value"`
+	}
+
+	advisorScoreEntity struct {
+		ID         *string `json:"id"`
+		Name       *string `json:"name"`
+		Properties *struct {
+			LastRefreshedScore *advisorScorePoint `json:"lastRefreshedScore"`
+			TimeSeries         []struct {
+				AggregationLevel *string             `json:"aggregationLevel"`
+				ScoreHistory     []advisorScorePoint `json:"scoreHistory"`
+			} `json:"timeSeries"`
+		} `json:"properties"`
+	}
+
+	advisorScorePoint struct {
+		Date                   *string  `json:"date"`
+		Score                  *float64 `json:"score"`
+		ConsumptionUnits       *float64 `json:"consumptionUnits"`
+		ImpactedResourceCount  *float64 `json:"impactedResourceCount"`
+		PotentialScoreIncrease *float64 `json:"potentialScoreIncrease"`
+	}
+)
+
+// collectAzureAdvisorScore collects the advisor score (category "advisor" is the overall score) of the subscription
+func (m *MetricsCollectorAzureRmAdvisor) collectAzureAdvisorScore(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
+	scoreMetric := m.Collector.GetMetricList("advisorScore")
+	potentialIncreaseMetric := m.Collector.GetMetricList("advisorScorePotentialIncrease")
+	impactedResourcesMetric := m.Collector.GetMetricList("advisorScoreImpactedResources")
+	consumptionUnitsMetric := m.Collector.GetMetricList("advisorScoreConsumptionUnits")
+	historyMetric := m.Collector.GetMetricList("advisorScoreHistory")
+
+	historyAggregation := Config.Collectors.Advisor.GetScoreHistoryAggregation()
+
+	requestUrl := armRestUrl(
+		*subscription.ID+"/providers/Microsoft.Advisor/advisorScore",
+		url.Values{
+			"api-version": {AdvisorScoreApiVersion},
+		},
+	)
+
+	result := advisorScoreList{}
+	if statusCode, err := armRestGet(m.Context(), requestUrl, &result); err != nil {
+		if isAzureUnsupportedScopeStatusCode(statusCode) {
+			logger.Warn(`unable to fetch advisor score`, slog.Int("statusCode", statusCode), slog.Any("error", err))
+			return
+		}
+		panic(err)
+	}
+
+	for _, scoreEntity := range result.Value {
+		if scoreEntity.Properties == nil {
+			continue
+		}
+
+		labels := prometheus.Labels{
+			"subscriptionID": to.StringLower(subscription.SubscriptionID),
+			"category":       to.StringLower(scoreEntity.Name),
+		}
+
+		if score := scoreEntity.Properties.LastRefreshedScore; score != nil {
+			scoreMetric.AddIfNotNil(labels, score.Score)
+			potentialIncreaseMetric.AddIfNotNil(labels, score.PotentialScoreIncrease)
+			impactedResourcesMetric.AddIfNotNil(labels, score.ImpactedResourceCount)
+			consumptionUnitsMetric.AddIfNotNil(labels, score.ConsumptionUnits)
+		}
+
+		for _, timeSeries := range scoreEntity.Properties.TimeSeries {
+			aggregationLevel := to.StringLower(timeSeries.AggregationLevel)
+			if aggregationLevel != historyAggregation {
+				continue
+			}
+
+			for _, point := range timeSeries.ScoreHistory {
+				date := to.String(point.Date)
+				// only use date part of timestamp
+				if i := strings.Index(date, "T"); i > 0 {
+					date = date[:i]
+				}
+
+				historyMetric.AddIfNotNil(prometheus.Labels{
+					"subscriptionID":   to.StringLower(subscription.SubscriptionID),
+					"category":         to.StringLower(scoreEntity.Name),
+					"aggregationLevel": aggregationLevel,
+					"date":             date,
+				}, point.Score)
+			}
+		}
+	}
+}
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/consumption/armconsumption"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/webdevops/go-common/prometheus/collector"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+const (
+	BillingInvoicesApiVersion = "2020-05-01"
+)
+
+type (
+	MetricsCollectorAzureRmBilling struct {
+		collector.Processor
+
+		prometheus struct {
+			invoiceInfo         *prometheus.GaugeVec
+			invoiceAmountDue    *prometheus.GaugeVec
+			invoiceBilledAmount *prometheus.GaugeVec
+			invoiceDate         *prometheus.GaugeVec
+			invoiceDueDate      *prometheus.GaugeVec
+
+			balance       *prometheus.GaugeVec
+			creditBalance *prometheus.GaugeVec
+
+			lotInfo           *prometheus.GaugeVec
+			lotOriginalAmount *prometheus.GaugeVec
+			lotClosedBalance  *prometheus.GaugeVec
+			lotUtilization    *prometheus.GaugeVec
+			lotExpiry         *prometheus.GaugeVec
+		}
+	}
+
+	// billingInvoiceList is the response of the invoices api (Microsoft.Billing)
+	billingInvoiceList struct {
+		Value    []billingInvoice `json:"value"`
+		NextLink *string          `json:"nextLink"`
+	}
+
+	billingInvoice struct {
+		ID         *string `json:"id"`
+		Name       *string `json:"name"`
+		Properties *struct {
+			AmountDue              *billingAmount `json:"amountDue"`
+			BilledAmount           *billingAmount `json:"billedAmount"`
+			DueDate                *time.Time     `json:"dueDate"`
+			InvoiceDate            *time.Time     `json:"invoiceDate"`
+			InvoicePeriodStartDate *time.Time     `json:"invoicePeriodStartDate"`
+			InvoicePeriodEndDate   *time.Time     `json:"invoicePeriodEndDate"`
+			Status                 *string        `json:"status"`
+			InvoiceType            *string        `json:"invoiceType"`
+			BillingProfileID       *string        `json:"billingProfileId"`
+		} `json:"properties"`
+	}
+
+	billingAmount struct {
+		Currency *string  `json:"currency"`
+		Value    *float64 `json:"value"`
+	}
+
+	// billingScope contains the ids of a billing account or billing profile scope
+	billingScope struct {
+		Scope            string
+		BillingAccountID string
+		BillingProfileID string
+	}
+)
+
+func (m *MetricsCollectorAzureRmBilling) Setup(collector *collector.Collector) {
+	m.Processor.Setup(collector)
+
+	// ----------------------------------------------------
+	// Invoices
+	m.prometheus.invoiceInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_invoice_info",
+			Help: "Azure ResourceManager billing invoice info",
+		},
+		[]string{
+			"scope",
+			"invoiceID",
+			"billingProfileID",
+			"invoiceType",
+			"status",
+		},
+	)
+	m.Collector.RegisterMetricList("invoiceInfo", m.prometheus.invoiceInfo, true)
+
+	m.prometheus.invoiceAmountDue = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_invoice_amount_due",
+			Help: "Azure ResourceManager billing invoice amount due",
+		},
+		[]string{
+			"scope",
+			"invoiceID",
+			"currency",
+		},
+	)
+	m.Collector.RegisterMetricList("invoiceAmountDue", m.prometheus.invoiceAmountDue, true)
+
+	m.prometheus.invoiceBilledAmount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_invoice_billed_amount",
+			Help: "Azure ResourceManager billing invoice billed amount",
+		},
+		[]string{
+			"scope",
+			"invoiceID",
+			"currency",
+		},
+	)
+	m.Collector.RegisterMetricList("invoiceBilledAmount", m.prometheus.invoiceBilledAmount, true)
+
+	m.prometheus.invoiceDate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_invoice_date_timestamp_seconds",
+			Help: "Azure ResourceManager billing invoice date",
+		},
+		[]string{
+			"scope",
+			"invoiceID",
+		},
+	)
+	m.Collector.RegisterMetricList("invoiceDate", m.prometheus.invoiceDate, true)
+
+	m.prometheus.invoiceDueDate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_invoice_due_timestamp_seconds",
+			Help: "Azure ResourceManager billing invoice due date",
+		},
+		[]string{
+			"scope",
+			"invoiceID",
+		},
+	)
+	m.Collector.RegisterMetricList("invoiceDueDate", m.prometheus.invoiceDueDate, true)
+
+	// ----------------------------------------------------
+	// Balances
+	m.prometheus.balance = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_balance",
+			Help: "Azure ResourceManager billing account balance of current billing period (Azure Prepayment, EA)",
+		},
+		[]string{
+			"scope",
+			"billingAccountID",
+			"type",
+			"currency",
+		},
+	)
+	m.Collector.RegisterMetricList("balance", m.prometheus.balance, true)
+
+	m.prometheus.creditBalance = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_credit_balance",
+			Help: "Azure ResourceManager billing profile credit balance (MCA)",
+		},
+		[]string{
+			"scope",
+			"billingAccountID",
+			"billingProfileID",
+			"type",
+			"currency",
+		},
+	)
+	m.Collector.RegisterMetricList("creditBalance", m.prometheus.creditBalance, true)
+
+	// ----------------------------------------------------
+	// Lots (credits and consumption commitments)
+	m.prometheus.lotInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_lot_info",
+			Help: "Azure ResourceManager billing lot info (credits and consumption commitments/MACC)",
+		},
+		[]string{
+			"scope",
+			"lotID",
+			"source",
+			"status",
+			"poNumber",
+		},
+	)
+	m.Collector.RegisterMetricList("lotInfo", m.prometheus.lotInfo, true)
+
+	m.prometheus.lotOriginalAmount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_lot_original_amount",
+			Help: "Azure ResourceManager billing lot original amount (eg. credit or commitment amount)",
+		},
+		[]string{
+			"scope",
+			"lotID",
+			"source",
+			"currency",
+		},
+	)
+	m.Collector.RegisterMetricList("lotOriginalAmount", m.prometheus.lotOriginalAmount, true)
+
+	m.prometheus.lotClosedBalance = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_lot_remaining_amount",
+			Help: "Azure ResourceManager billing lot remaining amount (closed balance)",
+		},
+		[]string{
+			"scope",
+			"lotID",
+			"source",
+			"currency",
+		},
+	)
+	m.Collector.RegisterMetricList("lotClosedBalance", m.prometheus.lotClosedBalance, true)
+
+	m.prometheus.lotUtilization = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_lot_utilization",
+			Help: "Azure ResourceManager billing lot utilization ratio (eg. MACC commitment progress)",
+		},
+		[]string{
+			"scope",
+			"lotID",
+			"source",
+		},
+	)
+	m.Collector.RegisterMetricList("lotUtilization", m.prometheus.lotUtilization, true)
+
+	m.prometheus.lotExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_billing_lot_expiry_timestamp_seconds",
+			Help: "Azure ResourceManager billing lot expiration date",
+		},
+		[]string{
+			"scope",
+			"lotID",
+			"source",
+		},
+	)
+	m.Collector.RegisterMetricList("lotExpiry", m.prometheus.lotExpiry, true)
+}
+
+func (m *MetricsCollectorAzureRmBilling) Reset() {}
+
+func (m *MetricsCollectorAzureRmBilling) Collect(callback chan<- func()) {
+	if len(Config.Collectors.Billing.Scopes) == 0 {
+		m.Logger().Warn(`no billing scopes configured`)
+		return
+	}
+
+	for _, scope := range Config.Collectors.Billing.Scopes {
+		scopeLogger := m.Logger().With(slog.String("scope", scope))
+
+		billingScope := parseBillingScope(scope)
+		if billingScope.BillingAccountID == "" {
+			scopeLogger.Error(`unable to parse billing scope, expected billing account or billing profile`)
+			continue
+		}
+
+		m.collectInvoices(scopeLogger, billingScope)
+		if billingScope.BillingProfileID != "" {
+			m.collectCreditBalance(scopeLogger, billingScope)
+		} else {
+			m.collectBalance(scopeLogger, billingScope)
+		}
+		m.collectLots(scopeLogger, billingScope)
+	}
+}
+
+// collectInvoices collects the invoices of the billing account or billing profile
+func (m *MetricsCollectorAzureRmBilling) collectInvoices(logger *slog.Logger, billingScope billingScope) {
+	infoMetric := m.Collector.GetMetricList("invoiceInfo")
+	amountDueMetric := m.Collector.GetMetricList("invoiceAmountDue")
+	billedAmountMetric := m.Collector.GetMetricList("invoiceBilledAmount")
+	dateMetric := m.Collector.GetMetricList("invoiceDate")
+	dueDateMetric := m.Collector.GetMetricList("invoiceDueDate")
+
+	now := time.Now()
+	requestUrl := armRestUrl(
+		billingScope.Scope+"/invoices",
+		url.Values{
+			"api-version":     {BillingInvoicesApiVersion},
+			"periodStartDate": {now.AddDate(0, 0, -Config.Collectors.Billing.GetInvoiceDays()).Format("2006-01-02")},
+			"periodEndDate":   {now.Format("2006-01-02")},
+		},
+	)
+
+	for requestUrl != "" {
+		result := billingInvoiceList{}
+		if statusCode, err := armRestGet(m.Context(), requestUrl, &result); err != nil {
+			if isAzureUnsupportedScopeStatusCode(statusCode) {
+				// invoices are not available for every billing account type
+				logger.Warn(`unable to fetch invoices`, slog.Int("statusCode", statusCode), slog.Any("error", err))
+				return
+			}
+			panic(err)
+		}
+
+		for _, invoice := range result.Value {
+			properties := invoice.Properties
+			if properties == nil {
+				continue
+			}
+
+			invoiceID := to.String(invoice.Name)
+
+			infoMetric.AddInfo(prometheus.Labels{
+				"scope":            billingScope.Scope,
+				"invoiceID":        invoiceID,
+				"billingProfileID": to.String(properties.BillingProfileID),
+				"invoiceType":      to.StringLower(properties.InvoiceType),
+				"status":           to.StringLower(properties.Status),
+			})
+
+			if amount := properties.AmountDue; amount != nil {
+				amountDueMetric.AddIfNotNil(prometheus.Labels{
+					"scope":     billingScope.Scope,
+					"invoiceID": invoiceID,
+					"currency":  to.String(amount.Currency),
+				}, amount.Value)
+			}
+
+			if amount := properties.BilledAmount; amount != nil {
+				billedAmountMetric.AddIfNotNil(prometheus.Labels{
+					"scope":     billingScope.Scope,
+					"invoiceID": invoiceID,
+					"currency":  to.String(amount.Currency),
+				}, amount.Value)
+			}
+
+			if properties.InvoiceDate != nil {
+				dateMetric.AddTime(prometheus.Labels{
+					"scope":     billingScope.Scope,
+					"invoiceID": invoiceID,
+				}, *properties.InvoiceDate)
+			}
+
+			if properties.DueDate != nil {
+				dueDateMetric.AddTime(prometheus.Labels{
+					"scope":     billingScope.Scope,
+					"invoiceID": invoiceID,
+				}, *properties.DueDate)
+			}
+		}
+
+		requestUrl = to.String(result.NextLink)
+	}
+}
+
+// collectBalance collects the balance of the current billing period of billing account (EA, Azure Prepayment)
+func (m *MetricsCollectorAzureRmBilling) collectBalance(logger *slog.Logger, billingScope billingScope) {
+	balanceMetric := m.Collector.GetMetricList("balance")
+
+	client, err := armconsumption.NewBalancesClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	result, err := client.GetByBillingAccount(m.Context(), billingScope.BillingAccountID, nil)
+	if err != nil {
+		if isAzureUnsupportedScopeError(err) {
+			// balances are only available for EA billing accounts
+			logger.Warn(`unable to fetch billing account balance`, slog.Any("error", err))
+			return
+		}
+		panic(err)
+	}
+
+	properties := result.Properties
+	if properties == nil {
+		return
+	}
+
+	balances := map[string]*float64{
+		"beginning":               properties.BeginningBalance,
+		"ending":                  properties.EndingBalance,
+		"newPurchases":            properties.NewPurchases,
+		"adjustments":             properties.Adjustments,
+		"utilized":                properties.Utilized,
+		"serviceOverage":          properties.ServiceOverage,
+		"chargesBilledSeparately": properties.ChargesBilledSeparately,
+		"totalOverage":            properties.TotalOverage,
+		"totalUsage":              properties.TotalUsage,
+		"marketplaceCharges":      properties.AzureMarketplaceServiceCharges,
+	}
+
+	for balanceType, value := range balances {
+		balanceMetric.AddIfNotNil(prometheus.Labels{
+			"scope":            billingScope.Scope,
+			"billingAccountID": billingScope.BillingAccountID,
+			"type":             balanceType,
+			"currency":         to.String(properties.Currency),
+		}, value)
+	}
+}
+
+// collectCreditBalance collects the credit balance of billing profile (MCA)
+func (m *MetricsCollectorAzureRmBilling) collectCreditBalance(logger *slog.Logger, billingScope billingScope) {
+	creditBalanceMetric := m.Collector.GetMetricList("creditBalance")
+
+	client, err := armconsumption.NewCreditsClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	result, err := client.Get(m.Context(), billingScope.BillingAccountID, billingScope.BillingProfileID, nil)
+	if err != nil {
+		if isAzureUnsupportedScopeError(err) {
+			// credits are only available for MCA billing profiles
+			logger.Warn(`unable to fetch billing profile credit balance`, slog.Any("error", err))
+			return
+		}
+		panic(err)
+	}
+
+	properties := result.Properties
+	if properties == nil {
+		return
+	}
+
+	balances := map[string]*armconsumption.Amount{
+		"pendingCreditAdjustments": properties.PendingCreditAdjustments,
+		"expiredCredit":            properties.ExpiredCredit,
+		"pendingEligibleCharges":   properties.PendingEligibleCharges,
+	}
+	if properties.BalanceSummary != nil {
+		balances["current"] = properties.BalanceSummary.CurrentBalance
+		balances["estimated"] = properties.BalanceSummary.EstimatedBalance
+	}
+
+	for balanceType, amount := range balances {
+		if amount == nil {
+			continue
+		}
+
+		creditBalanceMetric.AddIfNotNil(prometheus.Labels{
+			"scope":            billingScope.Scope,
+			"billingAccountID": billingScope.BillingAccountID,
+			"billingProfileID": billingScope.BillingProfileID,
+			"type":             balanceType,
+			"currency":         to.String(amount.Currency),
+		}, amount.Value)
+	}
+}
+
+// collectLots collects the credit and consumption commitment (MACC) lots of billing account or billing profile
+func (m *MetricsCollectorAzureRmBilling) collectLots(logger *slog.Logger, billingScope billingScope) {
+	client, err := armconsumption.NewLotsClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	if billingScope.BillingProfileID != "" {
+		pager := client.NewListByBillingProfilePager(billingScope.BillingAccountID, billingScope.BillingProfileID, nil)
+		for pager.More() {
+			result, err := pager.NextPage(m.Context())
+			if err != nil {
+				if isAzureUnsupportedScopeError(err) {
+					logger.Warn(`unable to fetch billing profile lots`, slog.Any("error", err))
+					return
+				}
+				panic(err)
+			}
+
+			m.addLotMetrics(billingScope, result.Value)
+		}
+	} else {
+		pager := client.NewListByBillingAccountPager(billingScope.BillingAccountID, nil)
+		for pager.More() {
+			result, err := pager.NextPage(m.Context())
+			if err != nil {
+				if isAzureUnsupportedScopeError(err) {
+					logger.Warn(`unable to fetch billing account lots`, slog.Any("error", err))
+					return
+				}
+				panic(err)
+			}
+
+			m.addLotMetrics(billingScope, result.Value)
+		}
+	}
+}
+
+func (m *MetricsCollectorAzureRmBilling) addLotMetrics(billingScope billingScope, lots []*armconsumption.LotSummary) {
+	infoMetric := m.Collector.GetMetricList("lotInfo")
+	originalAmountMetric := m.Collector.GetMetricList("lotOriginalAmount")
+	closedBalanceMetric := m.Collector.GetMetricList("lotClosedBalance")
+	utilizationMetric := m.Collector.GetMetricList("lotUtilization")
+	expiryMetric := m.Collector.GetMetricList("lotExpiry")
+
+	for _, lot := range lots {
+		properties := lot.Properties
+		if properties == nil {
+			continue
+		}
+
+		lotID := to.String(lot.Name)
+
+		source := ""
+		if properties.Source != nil {
+			source = stringToStringLower(string(*properties.Source))
+		}
+
+		status := ""
+		if properties.Status != nil {
+			status = stringToStringLower(string(*properties.Status))
+		}
+
+		infoMetric.AddInfo(prometheus.Labels{
+			"scope":    billingScope.Scope,
+			"lotID":    lotID,
+			"source":   source,
+			"status":   status,
+			"poNumber": to.String(properties.PoNumber),
+		})
+
+		if amount := properties.OriginalAmount; amount != nil {
+			originalAmountMetric.AddIfNotNil(prometheus.Labels{
+				"scope":    billingScope.Scope,
+				"lotID":    lotID,
+				"source":   source,
+				"currency": to.String(amount.Currency),
+			}, amount.Value)
+		}
+
+		if amount := properties.ClosedBalance; amount != nil {
+			closedBalanceMetric.AddIfNotNil(prometheus.Labels{
+				"scope":    billingScope.Scope,
+				"lotID":    lotID,
+				"source":   source,
+				"currency": to.String(amount.Currency),
+			}, amount.Value)
+		}
+
+		// consumed part of the lot (for consumption commitments the MACC progress)
+		if properties.OriginalAmount != nil && properties.OriginalAmount.Value != nil && *properties.OriginalAmount.Value != 0 &&
+			properties.ClosedBalance != nil && properties.ClosedBalance.Value != nil {
+			utilizationMetric.Add(prometheus.Labels{
+				"scope":  billingScope.Scope,
+				"lotID":  lotID,
+				"source": source,
+			}, 1-(*properties.ClosedBalance.Value / *properties.OriginalAmount.Value))
+		}
+
+		if properties.ExpirationDate != nil {
+			expiryMetric.AddTime(prometheus.Labels{
+				"scope":  billingScope.Scope,
+				"lotID":  lotID,
+				"source": source,
+			}, *properties.ExpirationDate)
+		}
+	}
+}
+
+// parseBillingScope parses billing account and billing profile id from scope
+// eg. /providers/Microsoft.Billing/billingAccounts/{billingAccountId}/billingProfiles/{billingProfileId}
+func parseBillingScope(scope string) billingScope {
+	ret := billingScope{Scope: scope}
+
+	parts := strings.Split(strings.Trim(scope, "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		switch strings.ToLower(parts[i]) {
+		case "billingaccounts":
+			ret.BillingAccountID = parts[i+1]
+		case "billingprofiles":
+			ret.BillingProfileID = parts[i+1]
+		}
+	}
+
+	return ret
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/consumption/armconsumption"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
@@ -11,18 +16,44 @@ import (
 	"github.com/webdevops/go-common/utils/to"
 )
 
-// Define MetricsCollectorAzureRmBudgets struct
-type MetricsCollectorAzureRmBudgets struct {
-	collector.Processor
+const (
+	BudgetsManagementGroupsApiVersion = "2021-04-01"
+)
+
+type (
+	// Define MetricsCollectorAzureRmBudgets struct
+	MetricsCollectorAzureRmBudgets struct {
+		collector.Processor
+
+		// budgets already collected in current run (budgets can be listed by multiple scopes)
+		budgetsSeen     map[string]bool
+		budgetsSeenLock sync.Mutex
+
+		prometheus struct {
+			consumptionBudgetInfo     *prometheus.GaugeVec
+			consumptionBudgetLimit    *prometheus.GaugeVec
+			consumptionBudgetCurrent  *prometheus.GaugeVec
+			consumptionBudgetForecast *prometheus.GaugeVec
+			consumptionBudgetUsage    *prometheus.GaugeVec
+			consumptionBudgetStart    *prometheus.GaugeVec
+			consumptionBudgetEnd      *prometheus.GaugeVec
+
+			consumptionBudgetNotificationInfo      *prometheus.GaugeVec
+			consumptionBudgetNotificationCount     *prometheus.GaugeVec
+			consumptionBudgetNotificationThreshold *prometheus.GaugeVec
+			consumptionBudgetNotificationCrossed   *prometheus.GaugeVec
+		}
+	}
 
-	prometheus struct {
-		consumptionBudgetInfo     *prometheus.GaugeVec
-		consumptionBudgetLimit    *prometheus.GaugeVec
-		consumptionBudgetCurrent  *prometheus.GaugeVec
-		consumptionBudgetForecast *prometheus.GaugeVec
-		consumptionBudgetUsage    *prometheus.GaugeVec
+	// managementGroupList is the response of the management groups api
+	managementGroupList struct {
+		Value []struct {
+			ID   *string `json:"id"`
+			Name *string `json:"name"`
+		} `json:"value"`
+		NextLink *string `json:"nextLink"`
 	}
-}
+)
 
 // Setup method to initialize Prometheus metrics
 func (m *MetricsCollectorAzureRmBudgets) Setup(collector *collector.Collector) {
@@ -35,15 +66,18 @@ func (m *MetricsCollectorAzureRmBudgets) Setup(collector *collector.Collector) {
 			Name: "azurerm_budgets_info",
 			Help: "Azure ResourceManager consumption budget info",
 		},
-		[]string{
-			"scope",
-			"resourceID",
-			"subscriptionID",
-			"budgetName",
-			"resourceGroup",
-			"category",
-			"timeGrain",
-		},
+		AzureResourceGroupTagManager.AddToPrometheusLabels(
+			[]string{
+				"scope",
+				"budgetScope",
+				"resourceID",
+				"subscriptionID",
+				"budgetName",
+				"resourceGroup",
+				"category",
+				"timeGrain",
+			},
+		),
 	)
 	m.Collector.RegisterMetricList("consumptionBudgetInfo", m.prometheus.consumptionBudgetInfo, true)
 
@@ -108,11 +142,113 @@ func (m *MetricsCollectorAzureRmBudgets) Setup(collector *collector.Collector) {
 		},
 	)
 	m.Collector.RegisterMetricList("consumptionBudgetForecast", m.prometheus.consumptionBudgetForecast, true)
+
+	m.prometheus.consumptionBudgetStart = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_budgets_start_timestamp_seconds",
+			Help: "Azure ResourceManager consumption budget start date",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"budgetName",
+		},
+	)
+	m.Collector.RegisterMetricList("consumptionBudgetStart", m.prometheus.consumptionBudgetStart, true)
+
+	m.prometheus.consumptionBudgetEnd = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_budgets_end_timestamp_seconds",
+			Help: "Azure ResourceManager consumption budget end date",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"budgetName",
+		},
+	)
+	m.Collector.RegisterMetricList("consumptionBudgetEnd", m.prometheus.consumptionBudgetEnd, true)
+
+	// ----------------------------------------------------
+	// Budget notifications
+	m.prometheus.consumptionBudgetNotificationInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_budgets_notification_info",
+			Help: "Azure ResourceManager consumption budget notification info",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"budgetName",
+			"notification",
+			"thresholdType",
+			"operator",
+			"enabled",
+		},
+	)
+	m.Collector.RegisterMetricList("consumptionBudgetNotificationInfo", m.prometheus.consumptionBudgetNotificationInfo, true)
+
+	m.prometheus.consumptionBudgetNotificationCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_budgets_notification_count",
+			Help: "Azure ResourceManager consumption budget count of enabled notifications",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"budgetName",
+		},
+	)
+	m.Collector.RegisterMetricList("consumptionBudgetNotificationCount", m.prometheus.consumptionBudgetNotificationCount, true)
+
+	m.prometheus.consumptionBudgetNotificationThreshold = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_budgets_notification_threshold",
+			Help: "Azure ResourceManager consumption budget notification threshold (percent of budget limit)",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"budgetName",
+			"notification",
+			"thresholdType",
+		},
+	)
+	m.Collector.RegisterMetricList("consumptionBudgetNotificationThreshold", m.prometheus.consumptionBudgetNotificationThreshold, true)
+
+	m.prometheus.consumptionBudgetNotificationCrossed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_budgets_notification_threshold_crossed",
+			Help: "Azure ResourceManager consumption budget notification threshold crossed (current or forecast spend)",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"budgetName",
+			"notification",
+			"thresholdType",
+		},
+	)
+	m.Collector.RegisterMetricList("consumptionBudgetNotificationCrossed", m.prometheus.consumptionBudgetNotificationCrossed, true)
 }
 
 func (m *MetricsCollectorAzureRmBudgets) Reset() {}
 
 func (m *MetricsCollectorAzureRmBudgets) Collect(callback chan<- func()) {
+	m.budgetsSeen = map[string]bool{}
+
 	if len(Config.Collectors.Budgets.Scopes) > 0 {
 		for _, scope := range Config.Collectors.Budgets.Scopes {
 			// Run the budget query for the current scope
@@ -128,15 +264,98 @@ func (m *MetricsCollectorAzureRmBudgets) Collect(callback chan<- func()) {
 				*subscription.ID,
 				callback,
 			)
+
+			if Config.Collectors.Budgets.Discovery.ResourceGroups {
+				m.collectResourceGroupBudgetMetrics(logger, subscription, callback)
+			}
 		})
 		if err != nil {
 			panic(err)
 		}
+
+		if Config.Collectors.Budgets.Discovery.ManagementGroups {
+			m.collectManagementGroupBudgetMetrics(m.Logger(), callback)
+		}
+	}
+}
+
+// collectResourceGroupBudgetMetrics collects the budgets of all resource groups of the subscription
+func (m *MetricsCollectorAzureRmBudgets) collectResourceGroupBudgetMetrics(logger *slog.Logger, subscription *armsubscriptions.Subscription, callback chan<- func()) {
+	list, err := AzureClient.ListResourceGroups(m.Context(), *subscription.SubscriptionID)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, resourceGroup := range list {
+		m.collectBudgetMetrics(
+			logger.With(slog.String("resourceGroup", to.String(resourceGroup.Name))),
+			to.String(resourceGroup.ID),
+			callback,
+		)
+	}
+}
+
+// collectManagementGroupBudgetMetrics collects the budgets of all management groups the exporter has access to
+func (m *MetricsCollectorAzureRmBudgets) collectManagementGroupBudgetMetrics(logger *slog.Logger, callback chan<- func()) {
+	requestUrl := armRestUrl(
+		"/providers/Microsoft.Management/managementGroups",
+		url.Values{
+			"api-version": {BudgetsManagementGroupsApiVersion},
+		},
+	)
+
+	for requestUrl != "" {
+		result := managementGroupList{}
+		if statusCode, err := armRestGet(m.Context(), requestUrl, &result); err != nil {
+			if statusCode == http.StatusForbidden || isAzureUnsupportedScopeStatusCode(statusCode) {
+				// management group discovery is optional, budgets of subscriptions are still collected
+				logger.Warn(`unable to list management groups, skipping management group budgets`, slog.Int("statusCode", statusCode), slog.Any("error", err))
+				return
+			}
+			panic(err)
+		}
+
+		for _, managementGroup := range result.Value {
+			m.collectBudgetMetrics(
+				logger.With(slog.String("managementGroup", to.String(managementGroup.Name))),
+				to.String(managementGroup.ID),
+				callback,
+			)
+		}
+
+		requestUrl = to.String(result.NextLink)
 	}
 }
 
+// markBudgetSeen returns true if the budget was not collected before in the current run
+func (m *MetricsCollectorAzureRmBudgets) markBudgetSeen(resourceId string) bool {
+	m.budgetsSeenLock.Lock()
+	defer m.budgetsSeenLock.Unlock()
+
+	resourceId = strings.ToLower(resourceId)
+	if m.budgetsSeen[resourceId] {
+		return false
+	}
+	m.budgetsSeen[resourceId] = true
+	return true
+}
+
+// budgetScope returns the scope of the budget from its resource id
+// eg. /subscriptions/{subscriptionId}/resourceGroups/{resourceGroup}/providers/Microsoft.Consumption/budgets/{budgetName}
+func budgetScope(resourceId, fallback string) string {
+	if index := strings.Index(strings.ToLower(resourceId), "/providers/microsoft.consumption/budgets/"); index > 0 {
+		return resourceId[:index]
+	}
+	return fallback
+}
+
+// isManagementGroupScope returns true if the scope is a management group
+func isManagementGroupScope(scope string) bool {
+	return strings.HasPrefix(strings.ToLower(scope), "/providers/microsoft.management/managementgroups/")
+}
+
 func (m *MetricsCollectorAzureRmBudgets) collectBudgetMetrics(logger *slog.Logger, scope string, callback chan<- func()) {
-	clientFactory, err := armconsumption.NewClientFactory("<subscription-id>", AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	client, err := armconsumption.NewBudgetsClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
 	if err != nil {
 		panic(err)
 	}
@@ -146,12 +365,18 @@ func (m *MetricsCollectorAzureRmBudgets) collectBudgetMetrics(logger *slog.Logge
 	limitMetric := m.Collector.GetMetricList("consumptionBudgetLimit")
 	currentMetric := m.Collector.GetMetricList("consumptionBudgetCurrent")
 	forecastMetric := m.Collector.GetMetricList("consumptionBudgetForecast")
+	startMetric := m.Collector.GetMetricList("consumptionBudgetStart")
+	endMetric := m.Collector.GetMetricList("consumptionBudgetEnd")
 
-	pager := clientFactory.NewBudgetsClient().NewListPager(scope, nil)
+	pager := client.NewListPager(scope, nil)
 
 	for pager.More() {
 		result, err := pager.NextPage(m.Context())
 		if err != nil {
+			if isManagementGroupScope(scope) && isAzureForbiddenError(err) {
+				logger.Warn(`unable to list budgets of management group, skipping`, slog.Any("error", err))
+				return
+			}
 			panic(err)
 		}
 
@@ -162,17 +387,35 @@ func (m *MetricsCollectorAzureRmBudgets) collectBudgetMetrics(logger *slog.Logge
 		for _, budget := range result.Value {
 			resourceId := to.String(budget.ID)
 
+			if !m.markBudgetSeen(resourceId) {
+				logger.Debug(`skipping budget, already collected`, slog.String("budgetID", resourceId))
+				continue
+			}
+
 			azureResource, _ := armclient.ParseResourceId(resourceId)
 
-			infoMetric.AddInfo(prometheus.Labels{
+			infoLabels := prometheus.Labels{
 				"scope":          scope,
+				"budgetScope":    budgetScope(resourceId, scope),
 				"resourceID":     stringToStringLower(resourceId),
 				"subscriptionID": azureResource.Subscription,
 				"resourceGroup":  azureResource.ResourceGroup,
 				"budgetName":     to.String(budget.Name),
 				"category":       stringToStringLower(string(*budget.Properties.Category)),
 				"timeGrain":      string(*budget.Properties.TimeGrain),
-			})
+			}
+
+			// add resourceGroup labels using tag manager
+			resourceGroupId := ""
+			if azureResource.Subscription != "" && azureResource.ResourceGroup != "" {
+				resourceGroupId = fmt.Sprintf(
+					"/subscriptions/%s/resourceGroups/%s",
+					azureResource.Subscription,
+					azureResource.ResourceGroup,
+				)
+			}
+			infoLabels = AzureResourceGroupTagManager.AddResourceTagsToPrometheusLabels(m.Context(), infoLabels, resourceGroupId)
+			infoMetric.AddInfo(infoLabels)
 
 			if budget.Properties.Amount != nil {
 				limitMetric.Add(prometheus.Labels{
@@ -215,6 +458,129 @@ func (m *MetricsCollectorAzureRmBudgets) collectBudgetMetrics(logger *slog.Logge
 					"budgetName":     to.String(budget.Name),
 				}, *budget.Properties.CurrentSpend.Amount / *budget.Properties.Amount)
 			}
+
+			if budget.Properties.TimePeriod != nil {
+				if budget.Properties.TimePeriod.StartDate != nil {
+					startMetric.AddTime(prometheus.Labels{
+						"scope":          scope,
+						"resourceID":     stringToStringLower(resourceId),
+						"subscriptionID": azureResource.Subscription,
+						"resourceGroup":  azureResource.ResourceGroup,
+						"budgetName":     to.String(budget.Name),
+					}, *budget.Properties.TimePeriod.StartDate)
+				}
+
+				if budget.Properties.TimePeriod.EndDate != nil {
+					endMetric.AddTime(prometheus.Labels{
+						"scope":          scope,
+						"resourceID":     stringToStringLower(resourceId),
+						"subscriptionID": azureResource.Subscription,
+						"resourceGroup":  azureResource.ResourceGroup,
+						"budgetName":     to.String(budget.Name),
+					}, *budget.Properties.TimePeriod.EndDate)
+				}
+			}
+
+			m.collectBudgetNotificationMetrics(scope, budget)
+		}
+	}
+}
+
+// collectBudgetNotificationMetrics exports the notifications of the budget and if their threshold is crossed
+func (m *MetricsCollectorAzureRmBudgets) collectBudgetNotificationMetrics(scope string, budget *armconsumption.Budget) {
+	infoMetric := m.Collector.GetMetricList("consumptionBudgetNotificationInfo")
+	countMetric := m.Collector.GetMetricList("consumptionBudgetNotificationCount")
+	thresholdMetric := m.Collector.GetMetricList("consumptionBudgetNotificationThreshold")
+	crossedMetric := m.Collector.GetMetricList("consumptionBudgetNotificationCrossed")
+
+	resourceId := to.String(budget.ID)
+	azureResource, _ := armclient.ParseResourceId(resourceId)
+
+	enabledCount := 0
+	for notificationName, notification := range budget.Properties.Notifications {
+		if notification == nil {
+			continue
+		}
+
+		enabled := notification.Enabled != nil && *notification.Enabled
+		if enabled {
+			enabledCount++
+		}
+
+		thresholdType := string(armconsumption.ThresholdTypeActual)
+		if notification.ThresholdType != nil {
+			thresholdType = string(*notification.ThresholdType)
+		}
+
+		operator := ""
+		if notification.Operator != nil {
+			operator = string(*notification.Operator)
+		}
+
+		infoMetric.AddInfo(prometheus.Labels{
+			"scope":          scope,
+			"resourceID":     stringToStringLower(resourceId),
+			"subscriptionID": azureResource.Subscription,
+			"resourceGroup":  azureResource.ResourceGroup,
+			"budgetName":     to.String(budget.Name),
+			"notification":   notificationName,
+			"thresholdType":  stringToStringLower(thresholdType),
+			"operator":       stringToStringLower(operator),
+			"enabled":        to.BoolString(enabled),
+		})
+
+		if notification.Threshold == nil {
+			continue
+		}
+
+		thresholdMetric.Add(prometheus.Labels{
+			"scope":          scope,
+			"resourceID":     stringToStringLower(resourceId),
+			"subscriptionID": azureResource.Subscription,
+			"resourceGroup":  azureResource.ResourceGroup,
+			"budgetName":     to.String(budget.Name),
+			"notification":   notificationName,
+			"thresholdType":  stringToStringLower(thresholdType),
+		}, *notification.Threshold)
+
+		// forecast notifications are compared against forecast spend, actual notifications against current spend
+		var spend *float64
+		if strings.EqualFold(thresholdType, string(armconsumption.ThresholdTypeForecasted)) {
+			if budget.Properties.ForecastSpend != nil {
+				spend = budget.Properties.ForecastSpend.Amount
+			}
+		} else if budget.Properties.CurrentSpend != nil {
+			spend = budget.Properties.CurrentSpend.Amount
+		}
+
+		if budget.Properties.Amount == nil || *budget.Properties.Amount == 0 || spend == nil {
+			continue
 		}
+
+		usagePercent := *spend / *budget.Properties.Amount * 100
+
+		// Azure sends the notification once the threshold is reached, so EqualTo is handled as reached
+		crossed := usagePercent >= *notification.Threshold
+		if notification.Operator != nil && *notification.Operator == armconsumption.OperatorTypeGreaterThan {
+			crossed = usagePercent > *notification.Threshold
+		}
+
+		crossedMetric.AddBool(prometheus.Labels{
+			"scope":          scope,
+			"resourceID":     stringToStringLower(resourceId),
+			"subscriptionID": azureResource.Subscription,
+			"resourceGroup":  azureResource.ResourceGroup,
+			"budgetName":     to.String(budget.Name),
+			"notification":   notificationName,
+			"thresholdType":  stringToStringLower(thresholdType),
+		}, crossed)
 	}
+
+	countMetric.Add(prometheus.Labels{
+		"scope":          scope,
+		"resourceID":     stringToStringLower(resourceId),
+		"subscriptionID": azureResource.Subscription,
+		"resourceGroup":  azureResource.ResourceGroup,
+		"budgetName":     to.String(budget.Name),
+	}, float64(enabledCount))
 }
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/costmanagement/armcostmanagement"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/webdevops/go-common/azuresdk/armclient"
+	"github.com/webdevops/go-common/prometheus/collector"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+const (
+	// CostAlertTypeAnomaly is the alert type of triggered anomaly alerts (not part of armcostmanagement.AlertType)
+	CostAlertTypeAnomaly = "Anomaly"
+)
+
+type (
+	MetricsCollectorAzureRmCostAlerts struct {
+		collector.Processor
+
+		prometheus struct {
+			costAlertInfo      *prometheus.GaugeVec
+			costAlertThreshold *prometheus.GaugeVec
+			costAlertAmount    *prometheus.GaugeVec
+			costAlertCurrent   *prometheus.GaugeVec
+			costAlertDetected  *prometheus.GaugeVec
+
+			anomalyAlertInfo     *prometheus.GaugeVec
+			anomalyAlertDetected *prometheus.GaugeVec
+			anomalyAlertCurrent  *prometheus.GaugeVec
+		}
+	}
+)
+
+func (m *MetricsCollectorAzureRmCostAlerts) Setup(collector *collector.Collector) {
+	m.Processor.Setup(collector)
+
+	// ----------------------------------------------------
+	// Cost alerts
+	m.prometheus.costAlertInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costs_alert_info",
+			Help: "Azure ResourceManager cost alert info",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"alertName",
+			"type",
+			"category",
+			"source",
+			"status",
+			"costEntityID",
+		},
+	)
+	m.Collector.RegisterMetricList("costAlertInfo", m.prometheus.costAlertInfo, true)
+
+	m.prometheus.costAlertThreshold = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costs_alert_threshold",
+			Help: "Azure ResourceManager cost alert notification threshold (percentage as decimal)",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"alertName",
+		},
+	)
+	m.Collector.RegisterMetricList("costAlertThreshold", m.prometheus.costAlertThreshold, true)
+
+	m.prometheus.costAlertAmount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costs_alert_amount",
+			Help: "Azure ResourceManager cost alert amount (eg. budget amount)",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"alertName",
+			"unit",
+		},
+	)
+	m.Collector.RegisterMetricList("costAlertAmount", m.prometheus.costAlertAmount, true)
+
+	m.prometheus.costAlertCurrent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costs_alert_current",
+			Help: "Azure ResourceManager cost alert current spend",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"alertName",
+			"unit",
+		},
+	)
+	m.Collector.RegisterMetricList("costAlertCurrent", m.prometheus.costAlertCurrent, true)
+
+	m.prometheus.costAlertDetected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costs_alert_detected_timestamp_seconds",
+			Help: "Azure ResourceManager cost alert creation timestamp",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"alertName",
+		},
+	)
+	m.Collector.RegisterMetricList("costAlertDetected", m.prometheus.costAlertDetected, true)
+
+	// ----------------------------------------------------
+	// Anomaly alerts (triggered by cost anomaly detection)
+	m.prometheus.anomalyAlertInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costs_anomalyalert_info",
+			Help: "Azure ResourceManager cost anomaly alert info",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"alertName",
+			"status",
+			"costEntityID",
+		},
+	)
+	m.Collector.RegisterMetricList("anomalyAlertInfo", m.prometheus.anomalyAlertInfo, true)
+
+	m.prometheus.anomalyAlertDetected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costs_anomalyalert_detected_timestamp_seconds",
+			Help: "Azure ResourceManager cost anomaly alert detection timestamp",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"alertName",
+		},
+	)
+	m.Collector.RegisterMetricList("anomalyAlertDetected", m.prometheus.anomalyAlertDetected, true)
+
+	m.prometheus.anomalyAlertCurrent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costs_anomalyalert_current",
+			Help: "Azure ResourceManager cost anomaly alert current spend",
+		},
+		[]string{
+			"scope",
+			"resourceID",
+			"subscriptionID",
+			"alertName",
+			"unit",
+		},
+	)
+	m.Collector.RegisterMetricList("anomalyAlertCurrent", m.prometheus.anomalyAlertCurrent, true)
+}
+
+func (m *MetricsCollectorAzureRmCostAlerts) Reset() {}
+
+func (m *MetricsCollectorAzureRmCostAlerts) Collect(callback chan<- func()) {
+	if len(Config.Collectors.CostAlerts.Scopes) > 0 {
+		for _, scope := range Config.Collectors.CostAlerts.Scopes {
+			scopeLogger := m.Logger().With(slog.String("scope", scope))
+			m.collectCostAlerts(scopeLogger, scope)
+		}
+	} else {
+		// using subscription iterator
+		iterator := AzureSubscriptionsIterator
+
+		err := iterator.ForEach(m.Logger(), func(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
+			m.collectCostAlerts(logger, *subscription.ID)
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (m *MetricsCollectorAzureRmCostAlerts) collectCostAlerts(logger *slog.Logger, scope string) {
+	client, err := armcostmanagement.NewAlertsClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	infoMetric := m.Collector.GetMetricList("costAlertInfo")
+	thresholdMetric := m.Collector.GetMetricList("costAlertThreshold")
+	amountMetric := m.Collector.GetMetricList("costAlertAmount")
+	currentMetric := m.Collector.GetMetricList("costAlertCurrent")
+	detectedMetric := m.Collector.GetMetricList("costAlertDetected")
+
+	result, err := client.List(m.Context(), scope, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	alertList := result.Value
+
+	// follow nextLink (not supported by alerts client)
+	nextLink := to.String(result.NextLink)
+	for nextLink != "" {
+		page := armcostmanagement.AlertsResult{}
+		if _, err := armRestGet(m.Context(), nextLink, &page); err != nil {
+			panic(err)
+		}
+
+		alertList = append(alertList, page.Value...)
+		nextLink = to.String(page.NextLink)
+	}
+
+	for _, alert := range alertList {
+		if alert.Properties == nil {
+			continue
+		}
+
+		status := ""
+		if alert.Properties.Status != nil {
+			status = string(*alert.Properties.Status)
+		}
+
+		if !Config.Collectors.CostAlerts.IsStatusEnabled(status) {
+			logger.Debug(`skipping cost alert because of status`, slog.String("alertID", to.String(alert.ID)), slog.String("status", status))
+			continue
+		}
+
+		resourceId := to.String(alert.ID)
+		azureResource, _ := armclient.ParseResourceId(resourceId)
+
+		alertType := ""
+		alertCategory := ""
+		if alert.Properties.Definition != nil {
+			if alert.Properties.Definition.Type != nil {
+				alertType = string(*alert.Properties.Definition.Type)
+			}
+			if alert.Properties.Definition.Category != nil {
+				alertCategory = string(*alert.Properties.Definition.Category)
+			}
+		}
+
+		// triggered anomaly alerts are exported separately
+		if strings.EqualFold(alertType, CostAlertTypeAnomaly) {
+			m.collectAnomalyAlert(logger, scope, alert, status)
+			continue
+		}
+
+		alertSource := ""
+		if alert.Properties.Source != nil {
+			alertSource = string(*alert.Properties.Source)
+		}
+
+		infoMetric.AddInfo(prometheus.Labels{
+			"scope":          scope,
+			"resourceID":     stringToStringLower(resourceId),
+			"subscriptionID": azureResource.Subscription,
+			"alertName":      to.String(alert.Name),
+			"type":           stringToStringLower(alertType),
+			"category":       stringToStringLower(alertCategory),
+			"source":         stringToStringLower(alertSource),
+			"status":         stringToStringLower(status),
+			"costEntityID":   to.StringLower(alert.Properties.CostEntityID),
+		})
+
+		if details := alert.Properties.Details; details != nil {
+			thresholdMetric.AddIfNotNil(prometheus.Labels{
+				"scope":          scope,
+				"resourceID":     stringToStringLower(resourceId),
+				"subscriptionID": azureResource.Subscription,
+				"alertName":      to.String(alert.Name),
+			}, details.Threshold)
+
+			amountMetric.AddIfNotNil(prometheus.Labels{
+				"scope":          scope,
+				"resourceID":     stringToStringLower(resourceId),
+				"subscriptionID": azureResource.Subscription,
+				"alertName":      to.String(alert.Name),
+				"unit":           to.StringLower(details.Unit),
+			}, details.Amount)
+
+			currentMetric.AddIfNotNil(prometheus.Labels{
+				"scope":          scope,
+				"resourceID":     stringToStringLower(resourceId),
+				"subscriptionID": azureResource.Subscription,
+				"alertName":      to.String(alert.Name),
+				"unit":           to.StringLower(details.Unit),
+			}, details.CurrentSpend)
+		}
+
+		if alert.Properties.CreationTime != nil {
+			if creationTime, err := time.Parse(time.RFC3339Nano, *alert.Properties.CreationTime); err == nil {
+				detectedMetric.AddTime(prometheus.Labels{
+					"scope":          scope,
+					"resourceID":     stringToStringLower(resourceId),
+					"subscriptionID": azureResource.Subscription,
+					"alertName":      to.String(alert.Name),
+				}, creationTime)
+			} else {
+				logger.Warn(`unable to parse cost alert creation time`, slog.String("alertID", resourceId), slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// collectAnomalyAlert collects a triggered anomaly alert with its detection time and current spend
+func (m *MetricsCollectorAzureRmCostAlerts) collectAnomalyAlert(logger *slog.Logger, scope string, alert *armcostmanagement.Alert, status string) {
+	infoMetric := m.Collector.GetMetricList("anomalyAlertInfo")
+	detectedMetric := m.Collector.GetMetricList("anomalyAlertDetected")
+	currentMetric := m.Collector.GetMetricList("anomalyAlertCurrent")
+
+	resourceId := to.String(alert.ID)
+	azureResource, _ := armclient.ParseResourceId(resourceId)
+
+	infoMetric.AddInfo(prometheus.Labels{
+		"scope":          scope,
+		"resourceID":     stringToStringLower(resourceId),
+		"subscriptionID": azureResource.Subscription,
+		"alertName":      to.String(alert.Name),
+		"status":         stringToStringLower(status),
+		"costEntityID":   to.StringLower(alert.Properties.CostEntityID),
+	})
+
+	if details := alert.Properties.Details; details != nil {
+		currentMetric.AddIfNotNil(prometheus.Labels{
+			"scope":          scope,
+			"resourceID":     stringToStringLower(resourceId),
+			"subscriptionID": azureResource.Subscription,
+			"alertName":      to.String(alert.Name),
+			"unit":           to.StringLower(details.Unit),
+		}, details.CurrentSpend)
+	}
+
+	if alert.Properties.CreationTime != nil {
+		if creationTime, err := time.Parse(time.RFC3339Nano, *alert.Properties.CreationTime); err == nil {
+			detectedMetric.AddTime(prometheus.Labels{
+				"scope":          scope,
+				"resourceID":     stringToStringLower(resourceId),
+				"subscriptionID": azureResource.Subscription,
+				"alertName":      to.String(alert.Name),
+			}, creationTime)
+		} else {
+			logger.Warn(`unable to parse anomaly alert creation time`, slog.String("alertID", resourceId), slog.Any("error", err))
+		}
+	}
+}
@@ -4,17 +4,22 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	armruntime "github.com/Azure/azure-sdk-for-go/sdk/azcore/arm/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/costmanagement/armcostmanagement"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/remeh/sizedwaitgroup"
 	"github.com/webdevops/go-common/azuresdk/armclient"
 	"github.com/webdevops/go-common/prometheus/collector"
 	"github.com/webdevops/go-common/utils/to"
@@ -33,7 +38,20 @@ type (
 
 		prometheus struct {
 			lastUpdate *prometheus.GaugeVec
+
+			rateLimitQpuRemaining      *prometheus.GaugeVec
+			rateLimitQpuConsumed       *prometheus.GaugeVec
+			rateLimitRequestsRemaining *prometheus.GaugeVec
+
+			currencyRate *prometheus.GaugeVec
 		}
+
+		rateLimit *metrics.CostRateLimitState
+
+		currencyConverter *costCurrencyConverter
+
+		history     map[string]*costQueryHistory
+		historyLock sync.Mutex
 	}
 
 	MetricsCollectorAzureRmCostsQuery struct {
@@ -55,6 +73,17 @@ type (
 		ResultColumnNumber int
 		LabelName          string
 	}
+
+	costQueryTagColumn struct {
+		KeyColumnNumber   int
+		ValueColumnNumber int
+	}
+
+	costQueryMetricRow struct {
+		Labels prometheus.Labels `json:"labels"`
+		Date   *time.Time        `json:"date,omitempty"`
+		Value  float64           `json:"value"`
+	}
 )
 
 func (m *MetricsCollectorAzureRmCosts) Setup(collector *collector.Collector) {
@@ -73,10 +102,75 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *collector.Collector) {
 	)
 	m.Collector.RegisterMetricList("lastUpdate", m.prometheus.lastUpdate, true)
 
+	// ----------------------------------------------------
+	// Rate limit headroom
+
+	rateLimitConfig := Config.Collectors.Costs.RateLimit
+	m.rateLimit = metrics.NewCostRateLimitState(
+		rateLimitConfig.GetBackoff(),
+		rateLimitConfig.GetMinQpuRemaining(),
+		rateLimitConfig.GetMinRequestsRemaining(),
+	)
+
+	m.prometheus.rateLimitQpuRemaining = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costs_ratelimit_qpu_remaining",
+			Help: "Azure ResourceManager cost api remaining query processing units (QPU) per quota window",
+		},
+		[]string{
+			"window",
+		},
+	)
+	m.Collector.RegisterMetricList("rateLimitQpuRemaining", m.prometheus.rateLimitQpuRemaining, true)
+
+	m.prometheus.rateLimitQpuConsumed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costs_ratelimit_qpu_consumed",
+			Help: "Azure ResourceManager cost api query processing units (QPU) consumed by last request",
+		},
+		[]string{},
+	)
+	m.Collector.RegisterMetricList("rateLimitQpuConsumed", m.prometheus.rateLimitQpuConsumed, true)
+
+	m.prometheus.rateLimitRequestsRemaining = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costs_ratelimit_requests_remaining",
+			Help: "Azure ResourceManager cost api remaining requests",
+		},
+		[]string{
+			"type",
+		},
+	)
+	m.Collector.RegisterMetricList("rateLimitRequestsRemaining", m.prometheus.rateLimitRequestsRemaining, true)
+
+	// ----------------------------------------------------
+	// Currency conversion
+
+	if Config.Collectors.Costs.Currency != nil {
+		m.currencyConverter = newCostCurrencyConverter(Config.Collectors.Costs.Currency)
+
+		m.prometheus.currencyRate = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "azurerm_costs_currency_conversion_rate",
+				Help: "Azure ResourceManager cost currency conversion rate into target currency",
+			},
+			[]string{
+				"currency",
+				"targetCurrency",
+			},
+		)
+		m.Collector.RegisterMetricList("currencyRate", m.prometheus.currencyRate, true)
+	}
+
 	// ----------------------------------------------------
 	// Costs (by Query)
 
-	for _, query := range Config.Collectors.Costs.Queries {
+	m.history = map[string]*costQueryHistory{}
+
+	for i := range Config.Collectors.Costs.Queries {
+		query := &Config.Collectors.Costs.Queries[i]
+
+		// build query config once, queries are processed concurrently
 		queryConfig := query.GetConfig()
 
 		costLabels := []string{
@@ -87,6 +181,10 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *collector.Collector) {
 			"granularity",
 		}
 
+		if query.IsForecast() {
+			costLabels = append(costLabels, "type")
+		}
+
 		// add dimension labels
 		for _, dimension := range queryConfig.Dimensions {
 			switch dimension.Label {
@@ -106,7 +204,7 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *collector.Collector) {
 			costLabels = append(costLabels, labelName)
 		}
 
-		if query.Granularity == "Daily" || query.Granularity == "Monthly" {
+		if (query.Granularity == "Daily" || query.Granularity == "Monthly") && !query.IsHistory() {
 			costLabels = append(costLabels, "date", "dateISO")
 		}
 
@@ -122,34 +220,104 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *collector.Collector) {
 			queryGaugeVec,
 			true,
 		)
+
+		if query.IsHistory() {
+			// restore history from state file (not part of collector cache)
+			m.getCostQueryHistory(query).Load(m.Logger())
+		}
+
+		if len(query.Allocation) > 0 {
+			allocatedGaugeVec := prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: query.GetMetricName() + "_allocated",
+					Help: query.GetMetricHelp() + " (with allocated shared costs)",
+				},
+				costLabels,
+			)
+			m.Collector.RegisterMetricList(
+				fmt.Sprintf(`query:%v:allocated`, query.Name),
+				allocatedGaugeVec,
+				true,
+			)
+		}
+
+		if m.currencyConverter != nil {
+			convertedGaugeVec := prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: query.GetMetricName() + "_converted",
+					Help: query.GetMetricHelp() + " (converted into target currency)",
+				},
+				append(slices.Clone(costLabels), "targetCurrency"),
+			)
+			m.Collector.RegisterMetricList(
+				fmt.Sprintf(`query:%v:converted`, query.Name),
+				convertedGaugeVec,
+				true,
+			)
+		}
 	}
 }
 
 func (m *MetricsCollectorAzureRmCosts) Reset() {}
 
 func (m *MetricsCollectorAzureRmCosts) Collect(callback chan<- func()) {
+	if m.currencyConverter != nil {
+		m.currencyConverter.Reload(m.Logger())
+	}
+
 	// run cost queries
-	for _, row := range Config.Collectors.Costs.Queries {
-		query := row
+	for i := range Config.Collectors.Costs.Queries {
+		query := &Config.Collectors.Costs.Queries[i]
 
 		exportType := armcostmanagement.ExportTypeActualCost
 		if strings.EqualFold(query.ExportType, "AmortizedCost") {
 			exportType = armcostmanagement.ExportTypeAmortizedCost
 		}
 
-		m.collectRunCostQuery(&query, exportType, callback)
+		m.collectRunCostQuery(query, exportType, callback)
+	}
+
+	// allocations can depend on other queries, so they are applied after all queries
+	for i := range Config.Collectors.Costs.Queries {
+		query := &Config.Collectors.Costs.Queries[i]
+		if len(query.Allocation) > 0 {
+			m.collectCostQueryAllocatedMetrics(m.Logger().With(slog.String("query", query.Name)), query)
+		}
+	}
+
+	m.collectRateLimitHeadroom()
+
+	if m.currencyConverter != nil {
+		m.collectCurrencyRates()
+	}
+}
+
+// collectRateLimitHeadroom exports the rate limit headroom reported by the last cost api response
+func (m *MetricsCollectorAzureRmCosts) collectRateLimitHeadroom() {
+	headroom := m.rateLimit.Headroom()
+
+	for window, val := range headroom.QpuRemaining {
+		m.Collector.GetMetricList("rateLimitQpuRemaining").Add(prometheus.Labels{"window": window}, val)
+	}
+
+	if headroom.QpuConsumed != nil {
+		m.Collector.GetMetricList("rateLimitQpuConsumed").Add(prometheus.Labels{}, *headroom.QpuConsumed)
+	}
+
+	for requestType, val := range headroom.RequestsRemaining {
+		m.Collector.GetMetricList("rateLimitRequestsRemaining").Add(prometheus.Labels{"type": requestType}, val)
 	}
 }
 
 func (m *MetricsCollectorAzureRmCosts) collectRunCostQuery(query *config.CollectorCostsQuery, exportType armcostmanagement.ExportType, callback chan<- func()) {
 	queryLogger := m.Logger().With(slog.String("query", query.Name))
-	for _, timeframe := range query.TimeFrames {
+	for _, timeframe := range query.GetTimeFrames() {
 		timeframeLogger := queryLogger.With(slog.String("timeframe", timeframe))
 		if query.Scopes != nil && len(*query.Scopes) > 0 {
 			// using custom scope
-			for _, scope := range *query.Scopes {
+			m.forEachCostQueryScopeAsync(*query.Scopes, func(scope string) {
 				m.collectCostManagementMetrics(
-					timeframeLogger,
+					timeframeLogger.With(slog.String("scope", scope)),
 					m.Collector.GetMetricList(fmt.Sprintf(`query:%v`, query.Name)),
 					scope,
 					exportType,
@@ -157,15 +325,17 @@ func (m *MetricsCollectorAzureRmCosts) collectRunCostQuery(query *config.Collect
 					timeframe,
 					nil,
 				)
-			}
+			})
 		} else {
-			// using subscription iterator
-			iterator := AzureSubscriptionsIterator
+			// using subscription iterator (own iterator as concurrency is limited by cost rate limits)
+			subscriptionFilter := Config.Azure.Subscriptions
 			if query.Subscriptions != nil && len(*query.Subscriptions) > 0 {
-				iterator = armclient.NewSubscriptionIterator(AzureClient, *query.Subscriptions...)
+				subscriptionFilter = *query.Subscriptions
 			}
+			iterator := armclient.NewSubscriptionIterator(AzureClient, subscriptionFilter...)
+			iterator.SetConcurrency(Config.Collectors.Costs.GetParallel())
 
-			err := iterator.ForEach(m.Logger(), func(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
+			err := iterator.ForEachAsync(m.Logger(), func(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
 				subscriptionLogger := timeframeLogger.With(slog.String("subscriptionID", *subscription.SubscriptionID))
 				m.collectCostManagementMetrics(
 					subscriptionLogger,
@@ -183,9 +353,59 @@ func (m *MetricsCollectorAzureRmCosts) collectRunCostQuery(query *config.Collect
 		}
 	}
 
+	if query.IsHistory() {
+		history := m.getCostQueryHistory(query)
+		history.Export(m.Collector.GetMetricList(fmt.Sprintf(`query:%v`, query.Name)))
+
+		if err := history.Save(); err != nil {
+			queryLogger.Error(`unable to write cost history state file`, slog.Any("error", err))
+		}
+
+		if query.History.OpenMetricsFile != "" {
+			if err := history.WriteOpenMetrics(queryLogger, query.History.OpenMetricsFile, query.GetMetricName(), query.GetMetricHelp()); err != nil {
+				queryLogger.Error(`unable to write cost history to OpenMetrics file`, slog.String("path", query.History.OpenMetricsFile), slog.Any("error", err))
+			}
+		}
+	}
+
+	if m.currencyConverter != nil {
+		m.collectCostQueryConvertedMetrics(queryLogger, query)
+	}
+
 	m.Collector.GetMetricList("lastUpdate").AddTime(prometheus.Labels{"metric": query.GetMetricName()}, time.Now())
 }
 
+// forEachCostQueryScopeAsync runs callback for each scope in parallel,
+// requests are paced by the cost rate limit policy
+func (m *MetricsCollectorAzureRmCosts) forEachCostQueryScopeAsync(scopeList []string, callback func(scope string)) {
+	var panicList []string
+	panicLock := sync.Mutex{}
+	wg := sizedwaitgroup.New(Config.Collectors.Costs.GetParallel())
+
+	for _, scope := range scopeList {
+		wg.Add()
+
+		go func(scope string) {
+			defer wg.Done()
+			defer func() {
+				if err := recover(); err != nil {
+					panicLock.Lock()
+					defer panicLock.Unlock()
+					panicList = append(panicList, fmt.Sprintf("scope %v: %v", scope, err))
+				}
+			}()
+
+			callback(scope)
+		}(scope)
+	}
+
+	wg.Wait()
+
+	if len(panicList) >= 1 {
+		panic("caught panics while processing cost query scopes: \n" + strings.Join(panicList, "\n"))
+	}
+}
+
 func (m *MetricsCollectorAzureRmCosts) collectCostManagementMetrics(logger *slog.Logger, metricList *collector.MetricList, scope string, exportType armcostmanagement.ExportType, query *config.CollectorCostsQuery, timeframe string, subscription *armsubscriptions.Subscription) {
 	logger.Info(`fetching cost report for query`, slog.String("query", query.Name))
 
@@ -201,16 +421,9 @@ func (m *MetricsCollectorAzureRmCosts) collectCostManagementMetrics(logger *slog
 			LabelName:          dimension.Label,
 		}
 
-		if strings.Contains(dimension.Dimension, ":") {
-			dimensionParts := strings.SplitN(dimension.Dimension, ":", 2)
-			switch strings.ToLower(dimensionParts[0]) {
-			case "tag":
-				dimensionConfig.Type = "TagKey"
-				dimensionConfig.Name = dimensionParts[1]
-				dimensionConfig.ResultColumnName = "TagValue"
-			default:
-				panic(fmt.Errorf(`cost dimension %v is not supported`, dimension))
-			}
+		if dimension.Type == config.CostsQueryDimensionTypeTag {
+			dimensionConfig.Type = "TagKey"
+			dimensionConfig.ResultColumnName = "TagValue"
 		}
 
 		dimensionList[i] = &dimensionConfig
@@ -246,6 +459,13 @@ func (m *MetricsCollectorAzureRmCosts) collectCostManagementMetrics(logger *slog
 		}
 	}
 
+	if query.IsHistory() {
+		// only fetch days which are not in history or still settling
+		from, to := m.getCostQueryHistory(query).FetchPeriod(scope, query.History)
+		timePeriod.From = &from
+		timePeriod.To = &to
+	}
+
 	if timeframe == "Custom" && (timePeriod.From == nil || timePeriod.To == nil) {
 		panic("if custom, then a specific time period must be provided.")
 	}
@@ -275,23 +495,130 @@ func (m *MetricsCollectorAzureRmCosts) collectCostManagementMetrics(logger *slog
 		params.TimePeriod = &timePeriod
 	}
 
-	result, err := m.sendCostQuery(m.Context(), logger, scope, params)
+	if query.IsForecast() {
+		m.collectCostManagementForecastMetrics(logger, metricList, scope, exportType, query, timeframe, subscription, params, dimensionList)
+	} else {
+		result, err := m.sendCostQuery(m.Context(), logger, scope, params)
+		if err != nil {
+			panic(err)
+		}
+
+		rows := m.processCostQueryResult(logger, scope, query, timeframe, subscription, result.QueryResult, dimensionList, nil)
+		if query.IsHistory() {
+			m.getCostQueryHistory(query).Update(scope, *timePeriod.From, rows, query.History)
+		} else {
+			addCostQueryMetricRows(metricList, rows)
+		}
+	}
+
+	// avoid rate limit (per scope worker, pacing based on rate limit headroom is shared between all workers)
+	time.Sleep(Config.Collectors.Costs.RequestDelay)
+}
+
+// collectCostManagementForecastMetrics runs the forecast api for the query
+// forecast api doesn't support grouping so dimension values are detected using an usage query
+// and one forecast is requested per dimension value combination
+func (m *MetricsCollectorAzureRmCosts) collectCostManagementForecastMetrics(logger *slog.Logger, metricList *collector.MetricList, scope string, exportType armcostmanagement.ExportType, query *config.CollectorCostsQuery, timeframe string, subscription *armsubscriptions.Subscription, usageParams armcostmanagement.QueryDefinition, dimensionList []*CostQueryConfigDimension) {
+	forecastType := armcostmanagement.ForecastType(exportType)
+	forecastTimeframe := armcostmanagement.ForecastTimeframeType(timeframe)
+	forecastGranularity := armcostmanagement.GranularityTypeDaily
+
+	includeActualCost := true
+	if query.IncludeActualCost != nil {
+		includeActualCost = *query.IncludeActualCost
+	}
+
+	params := armcostmanagement.ForecastDefinition{
+		Dataset: &armcostmanagement.ForecastDataset{
+			Aggregation: usageParams.Dataset.Aggregation,
+			Filter:      query.Filter,
+			Granularity: &forecastGranularity,
+		},
+		Timeframe:         &forecastTimeframe,
+		Type:              &forecastType,
+		IncludeActualCost: &includeActualCost,
+		TimePeriod:        usageParams.TimePeriod,
+	}
+
+	if len(dimensionList) == 0 {
+		result, err := m.sendCostForecast(m.Context(), logger, scope, params)
+		if err != nil {
+			panic(err)
+		}
+
+		addCostQueryMetricRows(metricList, m.processCostQueryResult(logger, scope, query, timeframe, subscription, result.QueryResult, dimensionList, nil))
+		return
+	}
+
+	// detect dimension values
+	usageGranularity := armcostmanagement.GranularityType("None")
+	usageDataset := *usageParams.Dataset
+	usageDataset.Granularity = &usageGranularity
+	usageParams.Dataset = &usageDataset
+
+	usageResult, err := m.sendCostQuery(m.Context(), logger, scope, usageParams)
 	if err != nil {
 		panic(err)
 	}
 
-	if result.Properties == nil || result.Properties.Columns == nil || result.Properties.Rows == nil {
+	if usageResult.Properties == nil || usageResult.Properties.Columns == nil || usageResult.Properties.Rows == nil {
 		// no result
 		logger.Warn("got invalid response (no columns or rows)")
 		return
 	}
 
+	tagColumnList, ok := m.detectCostQueryDimensionColumns(logger, usageResult.Properties.Columns, dimensionList)
+	if !ok {
+		return
+	}
+
+	dimensionValueList := [][]string{}
+	dimensionValueExists := map[string]bool{}
+	for _, row := range usageResult.Properties.Rows {
+		dimensionValues := resolveCostQueryDimensionValues(row, dimensionList, tagColumnList)
+
+		if slices.Contains(dimensionValues, "") {
+			logger.Debug(`skipping forecast for empty dimension value`, slog.Any("dimensionValues", dimensionValues))
+			continue
+		}
+
+		dimensionValueKey := strings.ToLower(strings.Join(dimensionValues, "\x00"))
+		if !dimensionValueExists[dimensionValueKey] {
+			dimensionValueExists[dimensionValueKey] = true
+			dimensionValueList = append(dimensionValueList, dimensionValues)
+		}
+	}
+
+	for _, dimensionValues := range dimensionValueList {
+		forecastDataset := *params.Dataset
+		forecastDataset.Filter = buildCostForecastFilter(query.Filter, dimensionList, dimensionValues)
+		params.Dataset = &forecastDataset
+
+		result, err := m.sendCostForecast(m.Context(), logger, scope, params)
+		if err != nil {
+			panic(err)
+		}
+
+		addCostQueryMetricRows(metricList, m.processCostQueryResult(logger, scope, query, timeframe, subscription, result.QueryResult, dimensionList, dimensionValues))
+	}
+}
+
+// processCostQueryResult converts the rows of an usage or forecast query result into metric rows
+// if dimensionValues is set, the dimension labels are taken from there instead of the result columns
+func (m *MetricsCollectorAzureRmCosts) processCostQueryResult(logger *slog.Logger, scope string, query *config.CollectorCostsQuery, timeframe string, subscription *armsubscriptions.Subscription, result armcostmanagement.QueryResult, dimensionList []*CostQueryConfigDimension, dimensionValues []string) []*costQueryMetricRow {
+	if result.Properties == nil || result.Properties.Columns == nil || result.Properties.Rows == nil {
+		// no result
+		logger.Warn("got invalid response (no columns or rows)")
+		return nil
+	}
+
 	list := result.Properties
 
 	// detect column numbers
 	columnNumberCost := -1
 	columnNumberCurrency := -1
 	columnNumberGranularityDate := -1
+	columnNumberCostStatus := -1
 	costValueFieldName := strings.ToLower(query.ValueField)
 
 	for num, col := range list.Columns {
@@ -312,29 +639,29 @@ func (m *MetricsCollectorAzureRmCosts) collectCostManagementMetrics(logger *slog
 			columnNumberCost = num
 		case "currency":
 			columnNumberCurrency = num
-		}
-
-		for _, dimensionConfig := range dimensionList {
-			if strings.EqualFold(dimensionConfig.ResultColumnName, *col.Name) {
-				dimensionConfig.ResultColumnNumber = num
-			}
+		case "coststatus":
+			columnNumberCostStatus = num
 		}
 	}
 
 	// check if we detected all columns
 	if columnNumberCost == -1 || columnNumberCurrency == -1 {
 		logger.Warn("unable to detect columns")
-		return
+		return nil
 	}
 
-	for _, dimensionConfig := range dimensionList {
-		if dimensionConfig.ResultColumnNumber == -1 {
-			logger.Warn(`unable to detect column`, slog.String("dimension", dimensionConfig.Name))
-			return
+	var tagColumnList []costQueryTagColumn
+	if dimensionValues == nil {
+		var ok bool
+		if tagColumnList, ok = m.detectCostQueryDimensionColumns(logger, list.Columns, dimensionList); !ok {
+			return nil
 		}
 	}
 
 	// process metrics
+	// forecast results are always daily, so rows are summed up by their labels
+	metricRows := []*costQueryMetricRow{}
+	metricRowIndex := map[string]*costQueryMetricRow{}
 	for _, row := range list.Rows {
 		usage := float64(0)
 		if v, ok := row[columnNumberCost].(float64); ok {
@@ -353,55 +680,70 @@ func (m *MetricsCollectorAzureRmCosts) collectCostManagementMetrics(logger *slog
 			labels["subscriptionID"] = *subscription.SubscriptionID
 		}
 
+		if query.IsForecast() {
+			labels["type"] = config.CostsQueryModeForecast
+			if columnNumberCostStatus != -1 {
+				if costStatus, ok := row[columnNumberCostStatus].(string); ok && costStatus != "" {
+					labels["type"] = stringToStringLower(costStatus)
+				}
+			}
+		}
+
+		var rowDate *time.Time
 		if columnNumberGranularityDate != -1 {
-			date := int64(0)
-			dateISO := ""
+			var datetime time.Time
 			switch v := row[columnNumberGranularityDate].(type) {
 			case float64:
-				datetime, err := time.Parse("20060102", strconv.FormatFloat(v, 'g', 8, 64))
+				val, err := time.Parse("20060102", strconv.FormatFloat(v, 'g', 8, 64))
 				if err != nil {
 					logger.Error("cannot parse date", slog.Any("date", v))
 				}
-				date = datetime.Unix()
-				dateISO = datetime.Format(time.RFC3339)
+				datetime = val
 			case string:
-				datetime, err := time.Parse("2006-01-02T00:00:00", v)
+				val, err := time.Parse("2006-01-02T00:00:00", v)
 				if err != nil {
 					logger.Error("cannot parse date %s", slog.Any("date", v))
 				}
-				date = datetime.Unix()
-				dateISO = datetime.Format(time.RFC3339)
+				datetime = val
+			}
+
+			if query.IsHistory() {
+				// history rows are exported with timestamp instead of date labels
+				rowDate = &datetime
+			} else {
+				labels["date"] = strconv.FormatInt(datetime.Unix(), 10)
+				labels["dateISO"] = datetime.Format(time.RFC3339)
 			}
-			labels["date"] = strconv.FormatInt(date, 10)
-			labels["dateISO"] = dateISO
 		}
 
-		for _, dimensionConfig := range dimensionList {
-			labels[dimensionConfig.LabelName] = ""
-			if row[dimensionConfig.ResultColumnNumber] != nil {
-				labels[dimensionConfig.LabelName] = row[dimensionConfig.ResultColumnNumber].(string)
+		rowDimensionValues := dimensionValues
+		if rowDimensionValues == nil {
+			rowDimensionValues = resolveCostQueryDimensionValues(row, dimensionList, tagColumnList)
+		}
 
-				switch dimensionConfig.LabelName {
-				case "subscriptionName":
-					if subscription != nil {
-						labels[dimensionConfig.LabelName] = to.String(subscription.DisplayName)
-					}
-				case "resourceGroup":
-					resourceId := ""
-					resourceGroup := row[dimensionConfig.ResultColumnNumber].(string)
-					if subscription != nil && resourceGroup != "" {
-						// add resourceGroups labels using tag manager
-						resourceId = fmt.Sprintf(
-							"/subscriptions/%s/resourceGroups/%s",
-							to.StringLower(subscription.SubscriptionID),
-							resourceGroup,
-						)
-					}
-					labels = AzureResourceGroupTagManager.AddResourceTagsToPrometheusLabels(m.Context(), labels, resourceId)
-				case "resourceID":
-					// add resource labels using tag manager
-					labels = AzureResourceTagManager.AddResourceTagsToPrometheusLabels(m.Context(), labels, row[dimensionConfig.ResultColumnNumber].(string))
+		for i, dimensionConfig := range dimensionList {
+			dimensionValue := rowDimensionValues[i]
+			labels[dimensionConfig.LabelName] = dimensionValue
+
+			switch dimensionConfig.LabelName {
+			case "subscriptionName":
+				if subscription != nil {
+					labels[dimensionConfig.LabelName] = to.String(subscription.DisplayName)
+				}
+			case "resourceGroup":
+				resourceId := ""
+				if subscription != nil && dimensionValue != "" {
+					// add resourceGroups labels using tag manager
+					resourceId = fmt.Sprintf(
+						"/subscriptions/%s/resourceGroups/%s",
+						to.StringLower(subscription.SubscriptionID),
+						dimensionValue,
+					)
 				}
+				labels = AzureResourceGroupTagManager.AddResourceTagsToPrometheusLabels(m.Context(), labels, resourceId)
+			case "resourceID":
+				// add resource labels using tag manager
+				labels = AzureResourceTagManager.AddResourceTagsToPrometheusLabels(m.Context(), labels, dimensionValue)
 			}
 		}
 
@@ -409,14 +751,116 @@ func (m *MetricsCollectorAzureRmCosts) collectCostManagementMetrics(logger *slog
 			labels[labelName] = labelValue
 		}
 
-		metricList.Add(labels, usage)
+		metricRowKey := costQueryMetricRowKey(labels)
+		if rowDate != nil {
+			metricRowKey += strconv.FormatInt(rowDate.Unix(), 10)
+		}
+
+		if metricRow, exists := metricRowIndex[metricRowKey]; exists {
+			metricRow.Value += usage
+		} else {
+			metricRow := &costQueryMetricRow{Labels: labels, Date: rowDate, Value: usage}
+			metricRowIndex[metricRowKey] = metricRow
+			metricRows = append(metricRows, metricRow)
+		}
 	}
 
-	// avoid rate limit
-	time.Sleep(Config.Collectors.Costs.RequestDelay)
+	return metricRows
 }
 
-func (m *MetricsCollectorAzureRmCosts) sendCostQuery(ctx context.Context, logger *slog.Logger, scope string, parameters armcostmanagement.QueryDefinition) (armcostmanagement.QueryClientUsageResponse, error) {
+func addCostQueryMetricRows(metricList *collector.MetricList, metricRows []*costQueryMetricRow) {
+	for _, metricRow := range metricRows {
+		metricList.Add(metricRow.Labels, metricRow.Value)
+	}
+}
+
+// detectCostQueryDimensionColumns detects the result columns of the dimensions
+// tag groupings are returned as TagKey/TagValue column pairs, which are matched to the tag dimensions per row
+func (m *MetricsCollectorAzureRmCosts) detectCostQueryDimensionColumns(logger *slog.Logger, columns []*armcostmanagement.QueryColumn, dimensionList []*CostQueryConfigDimension) ([]costQueryTagColumn, bool) {
+	tagColumnList := []costQueryTagColumn{}
+	tagDimensionCount := 0
+
+	for _, dimensionConfig := range dimensionList {
+		dimensionConfig.ResultColumnNumber = -1
+		if dimensionConfig.Type == "TagKey" {
+			tagDimensionCount++
+		}
+	}
+
+	columnNumberTagKey := -1
+	for num, col := range columns {
+		if col.Name == nil {
+			continue
+		}
+
+		switch {
+		case strings.EqualFold(*col.Name, "TagKey"):
+			columnNumberTagKey = num
+		case strings.EqualFold(*col.Name, "TagValue"):
+			tagColumnList = append(tagColumnList, costQueryTagColumn{
+				KeyColumnNumber:   columnNumberTagKey,
+				ValueColumnNumber: num,
+			})
+			columnNumberTagKey = -1
+		default:
+			for _, dimensionConfig := range dimensionList {
+				if dimensionConfig.Type != "TagKey" && strings.EqualFold(dimensionConfig.ResultColumnName, *col.Name) {
+					dimensionConfig.ResultColumnNumber = num
+				}
+			}
+		}
+	}
+
+	for _, dimensionConfig := range dimensionList {
+		if dimensionConfig.Type != "TagKey" && dimensionConfig.ResultColumnNumber == -1 {
+			logger.Warn(`unable to detect column`, slog.String("dimension", dimensionConfig.Name))
+			return nil, false
+		}
+	}
+
+	if tagDimensionCount > 0 && len(tagColumnList) == 0 {
+		logger.Warn(`unable to detect tag columns`)
+		return nil, false
+	}
+
+	return tagColumnList, true
+}
+
+// resolveCostQueryDimensionValues returns the dimension values of a result row (same order as dimensionList)
+func resolveCostQueryDimensionValues(row []interface{}, dimensionList []*CostQueryConfigDimension, tagColumnList []costQueryTagColumn) []string {
+	dimensionValues := make([]string, len(dimensionList))
+
+	tagDimensionIndexList := []int{}
+	for i, dimensionConfig := range dimensionList {
+		if dimensionConfig.Type == "TagKey" {
+			tagDimensionIndexList = append(tagDimensionIndexList, i)
+		} else if val, ok := row[dimensionConfig.ResultColumnNumber].(string); ok {
+			dimensionValues[i] = val
+		}
+	}
+
+	for num, tagColumn := range tagColumnList {
+		tagValue, _ := row[tagColumn.ValueColumnNumber].(string)
+
+		if tagColumn.KeyColumnNumber != -1 {
+			// match by tag key
+			tagKey, _ := row[tagColumn.KeyColumnNumber].(string)
+			for _, i := range tagDimensionIndexList {
+				if strings.EqualFold(dimensionList[i].Name, tagKey) {
+					dimensionValues[i] = tagValue
+					break
+				}
+			}
+		} else if num < len(tagDimensionIndexList) {
+			// no tag key column, match by order
+			dimensionValues[tagDimensionIndexList[num]] = tagValue
+		}
+	}
+
+	return dimensionValues
+}
+
+func (m *MetricsCollectorAzureRmCosts) newCostClientOptions(logger *slog.Logger) *arm.ClientOptions {
 	clientOpts := AzureClient.NewArmClientOptions()
 
 	// Initialize the client with appropriate retry options.
@@ -425,9 +869,29 @@ func (m *MetricsCollectorAzureRmCosts) sendCostQuery(ctx context.Context, logger
 		RetryDelay:    30 * time.Second,
 		MaxRetryDelay: 2 * time.Minute,
 	}
-	clientOpts.PerCallPolicies = append(clientOpts.PerCallPolicies, metrics.CostRateLimitPolicy{Logger: logger})
+	// rate limit policy is applied per retry so every attempt is paced and updates the rate limit headroom
+	clientOpts.PerRetryPolicies = append(clientOpts.PerRetryPolicies, metrics.CostRateLimitPolicy{Logger: logger, State: m.rateLimit})
+
+	return clientOpts
+}
+
+func (m *MetricsCollectorAzureRmCosts) sendCostQuery(ctx context.Context, logger *slog.Logger, scope string, parameters armcostmanagement.QueryDefinition) (armcostmanagement.QueryClientUsageResponse, error) {
+	client, err := armcostmanagement.NewQueryClient(AzureClient.GetCred(), m.newCostClientOptions(logger))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	result, err := client.Usage(ctx, scope, parameters, nil)
+	if err != nil {
+		panic(err.Error())
+	}
 
-	client, err := armcostmanagement.NewQueryClient(AzureClient.GetCred(), clientOpts)
+	err = m.fetchCostQueryNextPages(ctx, logger, &result.QueryResult, parameters)
+	return result, err
+}
+
+func (m *MetricsCollectorAzureRmCosts) sendCostForecast(ctx context.Context, logger *slog.Logger, scope string, parameters armcostmanagement.ForecastDefinition) (armcostmanagement.ForecastClientUsageResponse, error) {
+	client, err := armcostmanagement.NewForecastClient(AzureClient.GetCred(), m.newCostClientOptions(logger))
 	if err != nil {
 		panic(err.Error())
 	}
@@ -437,8 +901,18 @@ func (m *MetricsCollectorAzureRmCosts) sendCostQuery(ctx context.Context, logger
 		panic(err.Error())
 	}
 
+	err = m.fetchCostQueryNextPages(ctx, logger, &result.QueryResult, parameters)
+	return result, err
+}
+
+// fetchCostQueryNextPages follows the nextLink of the query result and appends the rows to the result
+func (m *MetricsCollectorAzureRmCosts) fetchCostQueryNextPages(ctx context.Context, logger *slog.Logger, result *armcostmanagement.QueryResult, parameters interface{}) error {
+	if result.Properties == nil {
+		return nil
+	}
+
 	// Set up the pipeline for paging.
-	pl, err := armruntime.NewPipeline("azurerm-costs", gitTag, AzureClient.GetCred(), runtime.PipelineOptions{}, AzureClient.NewArmClientOptions())
+	pl, err := armruntime.NewPipeline("azurerm-costs", gitTag, AzureClient.GetCred(), runtime.PipelineOptions{}, m.newCostClientOptions(logger))
 	if err != nil {
 		panic(err.Error())
 	}
@@ -475,7 +949,7 @@ func (m *MetricsCollectorAzureRmCosts) sendCostQuery(ctx context.Context, logger
 				}
 
 				if runtime.HasStatusCode(resp, http.StatusOK) {
-					pagerResult := armcostmanagement.QueryClientUsageResponse{}
+					pagerResult := armcostmanagement.QueryResult{}
 					if err := runtime.UnmarshalAsJSON(resp, &pagerResult); err == nil {
 						result.Properties.Rows = append(result.Properties.Rows, pagerResult.Properties.Rows...)
 						nextLink = pagerResult.Properties.NextLink
@@ -493,7 +967,7 @@ func (m *MetricsCollectorAzureRmCosts) sendCostQuery(ctx context.Context, logger
 				if strings.Contains(err.Error(), "received 429 Too Many Requests") {
 					continue
 				}
-				return result, err
+				return err
 			}
 
 		} else {
@@ -501,5 +975,49 @@ func (m *MetricsCollectorAzureRmCosts) sendCostQuery(ctx context.Context, logger
 		}
 	}
 
-	return result, err
+	return nil
+}
+
+// buildCostForecastFilter combines the query filter with filters for the dimension values
+func buildCostForecastFilter(queryFilter *armcostmanagement.QueryFilter, dimensionList []*CostQueryConfigDimension, dimensionValues []string) *armcostmanagement.QueryFilter {
+	filterList := []*armcostmanagement.QueryFilter{}
+	if queryFilter != nil {
+		filterList = append(filterList, queryFilter)
+	}
+
+	operator := armcostmanagement.QueryOperatorTypeIn
+	for i, dimensionConfig := range dimensionList {
+		expression := &armcostmanagement.QueryComparisonExpression{
+			Name:     to.StringPtr(dimensionConfig.Name),
+			Operator: &operator,
+			Values:   []*string{to.StringPtr(dimensionValues[i])},
+		}
+
+		switch dimensionConfig.Type {
+		case "TagKey":
+			filterList = append(filterList, &armcostmanagement.QueryFilter{Tags: expression})
+		default:
+			filterList = append(filterList, &armcostmanagement.QueryFilter{Dimensions: expression})
+		}
+	}
+
+	if len(filterList) == 1 {
+		return filterList[0]
+	}
+
+	return &armcostmanagement.QueryFilter{And: filterList}
+}
+
+func costQueryMetricRowKey(labels prometheus.Labels) string {
+	labelNames := slices.Sorted(maps.Keys(labels))
+
+	key := strings.Builder{}
+	for _, labelName := range labelNames {
+		key.WriteString(labelName)
+		key.WriteString("=")
+		key.WriteString(labels[labelName])
+		key.WriteString("\x00")
+	}
+
+	return key.String()
 }
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/webdevops/azure-resourcemanager-exporter/config"
+)
+
+// collectCostQueryAllocatedMetrics applies the allocation rules of the query on its results
+// and adds the result as allocated metric, has to run after all queries as allocations can
+// be proportional to other queries
+func (m *MetricsCollectorAzureRmCosts) collectCostQueryAllocatedMetrics(logger *slog.Logger, query *config.CollectorCostsQuery) {
+	metricRows := m.getCostQueryMetricRows(query.Name)
+
+	for num, allocation := range query.Allocation {
+		allocationLogger := logger.With(slog.Int("allocation", num), slog.String("label", allocation.Label))
+		metricRows = m.applyCostAllocation(allocationLogger, &allocation, metricRows)
+	}
+
+	addCostQueryMetricRows(m.Collector.GetMetricList(fmt.Sprintf(`query:%v:allocated`, query.Name)), metricRows)
+}
+
+// getCostQueryMetricRows returns the current metrics of a query
+func (m *MetricsCollectorAzureRmCosts) getCostQueryMetricRows(queryName string) []*costQueryMetricRow {
+	metricRows := []*costQueryMetricRow{}
+	for _, row := range m.Collector.GetMetricList(fmt.Sprintf(`query:%v`, queryName)).GetList() {
+		metricRows = append(metricRows, &costQueryMetricRow{Labels: row.Labels, Value: row.Value})
+	}
+	return metricRows
+}
+
+// applyCostAllocation splits the costs of the allocation sources across the targets
+func (m *MetricsCollectorAzureRmCosts) applyCostAllocation(logger *slog.Logger, allocation *config.CollectorCostsQueryAllocation, metricRows []*costQueryMetricRow) []*costQueryMetricRow {
+	labelName := allocation.Label
+
+	// tag labels of resourceGroup or resource are taken from existing rows of the target
+	var tagLabelNames []string
+	switch labelName {
+	case "resourceGroup":
+		tagLabelNames = AzureResourceGroupTagManager.AddToPrometheusLabels(tagLabelNames)
+	case "resourceID":
+		tagLabelNames = AzureResourceTagManager.AddToPrometheusLabels(tagLabelNames)
+	}
+
+	// target rows are looked up in the same scope, subscription, currency and timeframe as the source row
+	// (or at least in the same scope and subscription)
+	targetLabels := map[string]prometheus.Labels{}
+	for _, row := range metricRows {
+		for _, targetKey := range []string{
+			costAllocationKey(row.Labels, row.Labels["timeframe"], row.Labels[labelName]),
+			costAllocationScopeKey(row.Labels, row.Labels[labelName]),
+		} {
+			if _, exists := targetLabels[targetKey]; !exists {
+				targetLabels[targetKey] = row.Labels
+			}
+		}
+	}
+
+	var proportionalWeights map[string]map[string]float64
+	if allocation.ProportionalTo != nil {
+		proportionalWeights = m.buildCostAllocationWeights(allocation)
+	}
+
+	result := []*costQueryMetricRow{}
+	for _, row := range metricRows {
+		if !allocation.IsSource(row.Labels[labelName]) {
+			result = append(result, row)
+			continue
+		}
+
+		var shares map[string]float64
+		if allocation.ProportionalTo != nil {
+			// use weights of same scope, subscription, currency and timeframe if available
+			// or of all timeframes of same scope, subscription and currency
+			shares = proportionalWeights[costAllocationKey(row.Labels, row.Labels["timeframe"])]
+			if len(shares) == 0 {
+				shares = proportionalWeights[costAllocationKey(row.Labels, "")]
+			}
+		} else {
+			shares = map[string]float64{}
+			for target, percentage := range allocation.Targets {
+				shares[target] = percentage / 100
+			}
+		}
+
+		if len(shares) == 0 {
+			logger.Warn(`no allocation targets found, keeping costs on source`, slog.String("source", row.Labels[labelName]))
+			result = append(result, row)
+			continue
+		}
+
+		for target, share := range shares {
+			labels := maps.Clone(row.Labels)
+			labels[labelName] = target
+
+			targetRowLabels, targetExists := targetLabels[costAllocationKey(row.Labels, row.Labels["timeframe"], target)]
+			if !targetExists {
+				targetRowLabels, targetExists = targetLabels[costAllocationScopeKey(row.Labels, target)]
+			}
+			if targetExists {
+				labels[labelName] = targetRowLabels[labelName]
+			}
+			for _, tagLabelName := range tagLabelNames {
+				labels[tagLabelName] = ""
+				if targetExists {
+					labels[tagLabelName] = targetRowLabels[tagLabelName]
+				}
+			}
+
+			result = append(result, &costQueryMetricRow{Labels: labels, Value: row.Value * share})
+		}
+	}
+
+	return sumCostQueryMetricRows(result)
+}
+
+// buildCostAllocationWeights returns the share of every label value of the proportionalTo query,
+// indexed by costAllocationKey of scope, subscription, currency and timeframe
+// (empty timeframe contains the shares of all timeframes)
+func (m *MetricsCollectorAzureRmCosts) buildCostAllocationWeights(allocation *config.CollectorCostsQueryAllocation) map[string]map[string]float64 {
+	labelName := allocation.GetProportionalLabel()
+
+	values := map[string]map[string]float64{}
+	for _, row := range m.getCostQueryMetricRows(*allocation.ProportionalTo) {
+		labelValue := row.Labels[labelName]
+		if labelValue == "" || allocation.IsSource(labelValue) || row.Value <= 0 {
+			continue
+		}
+
+		for _, timeframe := range []string{row.Labels["timeframe"], ""} {
+			weightKey := costAllocationKey(row.Labels, timeframe)
+			if _, exists := values[weightKey]; !exists {
+				values[weightKey] = map[string]float64{}
+			}
+			values[weightKey][labelValue] += row.Value
+		}
+	}
+
+	weights := map[string]map[string]float64{}
+	for weightKey, weightValues := range values {
+		sum := 0.0
+		for _, val := range weightValues {
+			sum += val
+		}
+
+		weights[weightKey] = map[string]float64{}
+		for labelValue, val := range weightValues {
+			weights[weightKey][labelValue] = val / sum
+		}
+	}
+
+	return weights
+}
+
+// costAllocationKey builds the key of scope, subscription, currency and timeframe (with additional values, eg. target)
+// so costs are only allocated inside the same scope and subscription and values of different currencies are not mixed
+func costAllocationKey(labels prometheus.Labels, timeframe string, values ...string) string {
+	parts := append([]string{labels["scope"], labels["subscriptionID"], labels["currency"], timeframe}, values...)
+	return strings.ToLower(strings.Join(parts, "\x00"))
+}
+
+// costAllocationScopeKey builds the key of scope and subscription (with additional values, eg. target)
+func costAllocationScopeKey(labels prometheus.Labels, values ...string) string {
+	parts := append([]string{labels["scope"], labels["subscriptionID"]}, values...)
+	return strings.ToLower(strings.Join(parts, "\x00"))
+}
+
+// sumCostQueryMetricRows sums up rows with the same labels
+func sumCostQueryMetricRows(metricRows []*costQueryMetricRow) []*costQueryMetricRow {
+	result := []*costQueryMetricRow{}
+	index := map[string]*costQueryMetricRow{}
+	for _, row := range metricRows {
+		key := costQueryMetricRowKey(row.Labels)
+		if existingRow, exists := index[key]; exists {
+			existingRow.Value += row.Value
+		} else {
+			newRow := &costQueryMetricRow{Labels: row.Labels, Value: row.Value}
+			index[key] = newRow
+			result = append(result, newRow)
+		}
+	}
+	return result
+}
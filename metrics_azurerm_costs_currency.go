@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/webdevops/azure-resourcemanager-exporter/config"
+)
+
+type (
+	// costCurrencyConverter converts costs into the target currency,
+	// rates are reloaded periodically (if rates file is used)
+	costCurrencyConverter struct {
+		lock sync.Mutex
+
+		config *config.CollectorCostsCurrency
+
+		rates    map[string]float64
+		lastLoad time.Time
+	}
+)
+
+func newCostCurrencyConverter(currencyConfig *config.CollectorCostsCurrency) *costCurrencyConverter {
+	return &costCurrencyConverter{
+		config: currencyConfig,
+		rates:  map[string]float64{},
+	}
+}
+
+// Reload loads the rates if the reload interval has passed, on failure the previous rates are kept
+func (c *costCurrencyConverter) Reload(logger *slog.Logger) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.lastLoad.IsZero() && (c.config.RatesFile == "" || time.Since(c.lastLoad) < c.config.GetReloadInterval()) {
+		return
+	}
+
+	rates, err := c.config.LoadRates()
+	if err != nil {
+		logger.Error(`unable to load currency rates, using previous rates`, slog.Any("error", err))
+		return
+	}
+
+	logger.Debug(`loaded currency rates`, slog.Any("rates", rates))
+	c.rates = rates
+	c.lastLoad = time.Now()
+}
+
+// Rate returns the conversion rate from currency into the target currency
+func (c *costCurrencyConverter) Rate(currency string) (float64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	rate, exists := c.rates[strings.ToUpper(currency)]
+	return rate, exists
+}
+
+// Rates returns all known conversion rates
+func (c *costCurrencyConverter) Rates() map[string]float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return maps.Clone(c.rates)
+}
+
+// collectCostQueryConvertedMetrics adds the results of the query converted into the target currency
+func (m *MetricsCollectorAzureRmCosts) collectCostQueryConvertedMetrics(logger *slog.Logger, query *config.CollectorCostsQuery) {
+	targetCurrency := m.currencyConverter.config.GetTarget()
+	convertedMetric := m.Collector.GetMetricList(fmt.Sprintf(`query:%v:converted`, query.Name))
+
+	missingRates := map[string]bool{}
+	for _, row := range m.Collector.GetMetricList(fmt.Sprintf(`query:%v`, query.Name)).GetList() {
+		currency := row.Labels["currency"]
+
+		rate, exists := m.currencyConverter.Rate(currency)
+		if !exists {
+			missingRates[currency] = true
+			continue
+		}
+
+		labels := maps.Clone(row.Labels)
+		labels["targetCurrency"] = stringToStringLower(targetCurrency)
+		convertedMetric.Add(labels, row.Value*rate)
+	}
+
+	for currency := range missingRates {
+		logger.Warn(`no currency rate found, unable to convert costs`, slog.String("currency", currency), slog.String("targetCurrency", targetCurrency))
+	}
+}
+
+// collectCurrencyRates exports the conversion rates
+func (m *MetricsCollectorAzureRmCosts) collectCurrencyRates() {
+	targetCurrency := m.currencyConverter.config.GetTarget()
+	rateMetric := m.Collector.GetMetricList("currencyRate")
+
+	for currency, rate := range m.currencyConverter.Rates() {
+		rateMetric.Add(prometheus.Labels{
+			"currency":       stringToStringLower(currency),
+			"targetCurrency": stringToStringLower(targetCurrency),
+		}, rate)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/webdevops/go-common/prometheus/collector"
+
+	"github.com/webdevops/azure-resourcemanager-exporter/config"
+)
+
+type (
+	// costQueryHistory keeps the daily rows of a cost query,
+	// persisted in a state file next to the collector cache (collector cache only restores metrics)
+	costQueryHistory struct {
+		lock sync.Mutex
+
+		// path of state file (nil if cache is disabled)
+		path *string
+
+		// cache tag of query, state is discarded if query config changes
+		Tag string `json:"tag"`
+
+		// last fetch per scope
+		Scopes map[string]time.Time `json:"scopes"`
+
+		// daily rows, key is label set and date
+		Rows map[string]*costQueryMetricRow `json:"rows"`
+	}
+)
+
+func (m *MetricsCollectorAzureRmCosts) getCostQueryHistory(query *config.CollectorCostsQuery) *costQueryHistory {
+	m.historyLock.Lock()
+	defer m.historyLock.Unlock()
+
+	if history, exists := m.history[query.Name]; exists {
+		return history
+	}
+
+	history := &costQueryHistory{
+		path:   Opts.GetCachePath(fmt.Sprintf(`costs.history.%v.json`, query.Name)),
+		Tag:    *collector.BuildCacheTag(cacheTag, Config.Azure, query),
+		Scopes: map[string]time.Time{},
+		Rows:   map[string]*costQueryMetricRow{},
+	}
+	m.history[query.Name] = history
+	return history
+}
+
+// Load restores the history from the state file
+func (h *costQueryHistory) Load(logger *slog.Logger) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.path == nil {
+		return
+	}
+
+	/* #nosec G304 */
+	content, err := os.ReadFile(*h.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Warn(`unable to read cost history state file`, slog.String("path", *h.path), slog.Any("error", err))
+		}
+		return
+	}
+
+	state := costQueryHistory{}
+	if err := json.Unmarshal(content, &state); err != nil {
+		logger.Warn(`unable to parse cost history state file`, slog.String("path", *h.path), slog.Any("error", err))
+		return
+	}
+
+	if state.Tag != h.Tag {
+		logger.Info(`ignoring cost history state file, query config changed`, slog.String("path", *h.path))
+		return
+	}
+
+	if state.Scopes != nil {
+		h.Scopes = state.Scopes
+	}
+	if state.Rows != nil {
+		h.Rows = state.Rows
+	}
+
+	logger.Info(`restored cost history from state file`, slog.String("path", *h.path), slog.Int("rows", len(h.Rows)))
+}
+
+// Save writes the history to the state file
+func (h *costQueryHistory) Save() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.path == nil {
+		return nil
+	}
+
+	content, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(*h.path, content)
+}
+
+// FetchPeriod returns the time period which needs to be fetched for scope
+// first run fetches all history days, following runs only the days which are still settling
+func (h *costQueryHistory) FetchPeriod(scope string, historyConfig *config.CollectorCostsQueryHistory) (time.Time, time.Time) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+
+	days := historyConfig.GetDays()
+	if _, exists := h.Scopes[scope]; exists {
+		days = historyConfig.GetSettleDays()
+	}
+
+	return today.AddDate(0, 0, -days), now
+}
+
+// Update replaces the rows of scope starting with date from and removes expired rows
+func (h *costQueryHistory) Update(scope string, from time.Time, rows []*costQueryMetricRow, historyConfig *config.CollectorCostsQueryHistory) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	from = from.Truncate(24 * time.Hour)
+	expiry := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -historyConfig.GetDays())
+
+	for key, row := range h.Rows {
+		if row.Date == nil || row.Date.Before(expiry) || (row.Labels["scope"] == scope && !row.Date.Before(from)) {
+			delete(h.Rows, key)
+		}
+	}
+
+	for _, row := range rows {
+		if row.Date == nil || row.Date.Before(expiry) {
+			continue
+		}
+		h.Rows[costQueryMetricRowKey(row.Labels)+strconv.FormatInt(row.Date.Unix(), 10)] = row
+	}
+
+	h.Scopes[scope] = time.Now()
+}
+
+// Export adds the rows of the latest complete day (per scope) to the metric list
+func (h *costQueryHistory) Export(metricList *collector.MetricList) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for _, row := range latestCompleteDayCostQueryRows(h.Rows, today) {
+		metricList.Add(row.Labels, row.Value)
+	}
+}
+
+// latestCompleteDayCostQueryRows returns the rows of the latest complete day with costs per scope,
+// series without costs on that day are not returned (same as non history mode)
+func latestCompleteDayCostQueryRows(rows map[string]*costQueryMetricRow, today time.Time) []*costQueryMetricRow {
+	latestDay := map[string]time.Time{}
+	for _, row := range rows {
+		if row.Date == nil || !row.Date.Before(today) {
+			continue
+		}
+
+		scope := row.Labels["scope"]
+		if day, exists := latestDay[scope]; !exists || row.Date.After(day) {
+			latestDay[scope] = *row.Date
+		}
+	}
+
+	ret := []*costQueryMetricRow{}
+	for _, row := range rows {
+		if day, exists := latestDay[row.Labels["scope"]]; exists && row.Date != nil && row.Date.Equal(day) {
+			ret = append(ret, row)
+		}
+	}
+
+	return ret
+}
+
+// WriteOpenMetrics writes the history with timestamps as OpenMetrics file,
+// can be imported into Prometheus using "promtool tsdb create-blocks-from openmetrics"
+func (h *costQueryHistory) WriteOpenMetrics(logger *slog.Logger, path, metricName, metricHelp string) error {
+	h.lock.Lock()
+	rowList := make([]*costQueryMetricRow, 0, len(h.Rows))
+	for _, row := range h.Rows {
+		rowList = append(rowList, row)
+	}
+	h.lock.Unlock()
+
+	// samples of a series have to be in order
+	slices.SortFunc(rowList, func(a, b *costQueryMetricRow) int {
+		if c := strings.Compare(costQueryMetricRowKey(a.Labels), costQueryMetricRowKey(b.Labels)); c != 0 {
+			return c
+		}
+		return a.Date.Compare(*b.Date)
+	})
+
+	content := strings.Builder{}
+	content.WriteString(fmt.Sprintf("# HELP %s %s\n", metricName, escapeOpenMetricsString(metricHelp, false)))
+	content.WriteString(fmt.Sprintf("# TYPE %s gauge\n", metricName))
+	for _, row := range rowList {
+		content.WriteString(metricName)
+		content.WriteString("{")
+		for i, labelName := range slices.Sorted(maps.Keys(row.Labels)) {
+			if i > 0 {
+				content.WriteString(",")
+			}
+			content.WriteString(fmt.Sprintf(`%s="%s"`, labelName, escapeOpenMetricsString(row.Labels[labelName], true)))
+		}
+		content.WriteString("} ")
+		content.WriteString(strconv.FormatFloat(row.Value, 'g', -1, 64))
+		content.WriteString(" ")
+		content.WriteString(strconv.FormatInt(row.Date.Unix(), 10))
+		content.WriteString("\n")
+	}
+	content.WriteString("# EOF\n")
+
+	if err := writeFileAtomic(path, []byte(content.String())); err != nil {
+		return err
+	}
+
+	logger.Info(`written cost history to OpenMetrics file`, slog.String("path", path), slog.Int("samples", len(rowList)))
+	return nil
+}
+
+// writeFileAtomic writes to temporary file first to avoid partial files
+func writeFileAtomic(path string, content []byte) error {
+	tmpPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmpPath, content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func escapeOpenMetricsString(val string, quote bool) string {
+	val = strings.ReplaceAll(val, `\`, `\\`)
+	val = strings.ReplaceAll(val, "\n", `\n`)
+	if quote {
+		val = strings.ReplaceAll(val, `"`, `\"`)
+	}
+	return val
+}
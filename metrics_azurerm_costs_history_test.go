@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLatestCompleteDayCostQueryRows(t *testing.T) {
+	today := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	day := func(offset int) *time.Time {
+		ret := today.AddDate(0, 0, offset)
+		return &ret
+	}
+
+	rows := map[string]*costQueryMetricRow{}
+	addRow := func(scope, resourceGroup string, date *time.Time, value float64) {
+		labels := prometheus.Labels{"scope": scope, "resourceGroup": resourceGroup}
+		rows[costQueryMetricRowKey(labels)+date.String()] = &costQueryMetricRow{Labels: labels, Date: date, Value: value}
+	}
+
+	// scope a: rg2 dropped out two days ago, today is incomplete
+	addRow("a", "rg1", day(-3), 1)
+	addRow("a", "rg2", day(-3), 2)
+	addRow("a", "rg1", day(-2), 3)
+	addRow("a", "rg2", day(-2), 4)
+	addRow("a", "rg1", day(-1), 5)
+	addRow("a", "rg1", day(0), 6)
+
+	// scope b: latest complete day is older than scope a
+	addRow("b", "rg1", day(-3), 7)
+	addRow("b", "rg2", day(-2), 8)
+
+	result := latestCompleteDayCostQueryRows(rows, today)
+
+	values := []float64{}
+	for _, row := range result {
+		values = append(values, row.Value)
+	}
+	slices.Sort(values)
+
+	expected := []float64{5, 8}
+	if !slices.Equal(values, expected) {
+		t.Errorf("expected values %v, got %v", expected, values)
+	}
+}
+
+func TestLatestCompleteDayCostQueryRowsEmpty(t *testing.T) {
+	today := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	rows := map[string]*costQueryMetricRow{
+		"a": {Labels: prometheus.Labels{"scope": "a"}, Date: &today, Value: 1},
+	}
+
+	if result := latestCompleteDayCostQueryRows(rows, today); len(result) != 0 {
+		t.Errorf("expected no rows for incomplete day, got %d", len(result))
+	}
+}
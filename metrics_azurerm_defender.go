@@ -2,10 +2,10 @@ package main
 
 import (
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/advisor/armadvisor"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/security/armsecurity"
 	"github.com/prometheus/client_golang/prometheus"
@@ -22,9 +22,28 @@ type MetricsCollectorAzureRmDefender struct {
 		defenderSecureScoreMax        *prometheus.GaugeVec
 		defenderSecureScoreCurrent    *prometheus.GaugeVec
 
+		defenderSecureScoreControlInfo       *prometheus.GaugeVec
+		defenderSecureScoreControlPercentage *prometheus.GaugeVec
+		defenderSecureScoreControlMax        *prometheus.GaugeVec
+		defenderSecureScoreControlCurrent    *prometheus.GaugeVec
+		defenderSecureScoreControlResources  *prometheus.GaugeVec
+
 		defenderComplianceScore         *prometheus.GaugeVec
 		defenderComplianceResourceCount *prometheus.GaugeVec
 		defenderAdvisorRecommendations  *prometheus.GaugeVec
+
+		defenderAssessmentUnhealthy *prometheus.GaugeVec
+		defenderAssessmentCount     *prometheus.GaugeVec
+
+		defenderPlanInfo           *prometheus.GaugeVec
+		defenderPlanEnablementTime *prometheus.GaugeVec
+		defenderPlanCompliant      *prometheus.GaugeVec
+
+		defenderRegulatoryComplianceStandardInfo        *prometheus.GaugeVec
+		defenderRegulatoryComplianceStandardControls    *prometheus.GaugeVec
+		defenderRegulatoryComplianceControlInfo         *prometheus.GaugeVec
+		defenderRegulatoryComplianceControlAssessments  *prometheus.GaugeVec
+		defenderRegulatoryComplianceAssessmentResources *prometheus.GaugeVec
 	}
 }
 
@@ -67,6 +86,70 @@ func (m *MetricsCollectorAzureRmDefender) Setup(collector *collector.Collector)
 	)
 	m.Collector.RegisterMetricList("defenderSecureScoreCurrent", m.prometheus.defenderSecureScoreCurrent, true)
 
+	m.prometheus.defenderSecureScoreControlInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_defender_secure_score_control_info",
+			Help: "Azure Defender secure score control information",
+		},
+		[]string{
+			"subscriptionID",
+			"controlName",
+			"displayName",
+			"category",
+			"sourceType",
+		},
+	)
+	m.Collector.RegisterMetricList("defenderSecureScoreControlInfo", m.prometheus.defenderSecureScoreControlInfo, true)
+
+	m.prometheus.defenderSecureScoreControlPercentage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_defender_secure_score_control_percentage",
+			Help: "Azure Defender secure score control in percent",
+		},
+		[]string{
+			"subscriptionID",
+			"controlName",
+		},
+	)
+	m.Collector.RegisterMetricList("defenderSecureScoreControlPercentage", m.prometheus.defenderSecureScoreControlPercentage, true)
+
+	m.prometheus.defenderSecureScoreControlMax = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_defender_secure_score_control_max",
+			Help: "Azure Defender maximum secure score which can be achieved by control",
+		},
+		[]string{
+			"subscriptionID",
+			"controlName",
+		},
+	)
+	m.Collector.RegisterMetricList("defenderSecureScoreControlMax", m.prometheus.defenderSecureScoreControlMax, true)
+
+	m.prometheus.defenderSecureScoreControlCurrent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_defender_secure_score_control_current",
+			Help: "Azure Defender current secure score of control",
+		},
+		[]string{
+			"subscriptionID",
+			"controlName",
+		},
+	)
+	m.Collector.RegisterMetricList("defenderSecureScoreControlCurrent", m.prometheus.defenderSecureScoreControlCurrent, true)
+
+	m.prometheus.defenderSecureScoreControlResources = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_defender_secure_score_control_resources",
+			Help: "Azure Defender secure score control count of resources by status",
+		},
+		[]string{
+			"subscriptionID",
+			"controlName",
+			"status",
+		},
+	)
+	m.Collector.RegisterMetricList("defenderSecureScoreControlResources", m.prometheus.defenderSecureScoreControlResources, true)
+
 	m.prometheus.defenderComplianceScore = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_defender_compliance_score",
@@ -107,6 +190,154 @@ func (m *MetricsCollectorAzureRmDefender) Setup(collector *collector.Collector)
 		},
 	)
 	m.Collector.RegisterMetricList("defenderAdvisorRecommendations", m.prometheus.defenderAdvisorRecommendations, true)
+
+	if Config.Collectors.Defender.Assessments.Enabled {
+		m.prometheus.defenderAssessmentUnhealthy = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "azurerm_defender_assessment_unhealthy",
+				Help: "Azure Defender unhealthy security assessment of resource",
+			},
+			AzureResourceTagManager.AddToPrometheusLabels(
+				[]string{
+					"subscriptionID",
+					"resourceID",
+					"assessmentName",
+					"displayName",
+					"severity",
+					"statusCause",
+				},
+			),
+		)
+		m.Collector.RegisterMetricList("defenderAssessmentUnhealthy", m.prometheus.defenderAssessmentUnhealthy, true)
+
+		m.prometheus.defenderAssessmentCount = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "azurerm_defender_assessment_count",
+				Help: "Azure Defender count of security assessments by severity and status",
+			},
+			[]string{
+				"subscriptionID",
+				"severity",
+				"status",
+			},
+		)
+		m.Collector.RegisterMetricList("defenderAssessmentCount", m.prometheus.defenderAssessmentCount, true)
+	}
+
+	if Config.Collectors.Defender.Plans.Enabled {
+		m.prometheus.defenderPlanInfo = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "azurerm_defender_plan_info",
+				Help: "Azure Defender plan (pricing tier) of subscription",
+			},
+			[]string{
+				"subscriptionID",
+				"plan",
+				"pricingTier",
+				"subPlan",
+			},
+		)
+		m.Collector.RegisterMetricList("defenderPlanInfo", m.prometheus.defenderPlanInfo, true)
+
+		m.prometheus.defenderPlanEnablementTime = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "azurerm_defender_plan_enablement_timestamp_seconds",
+				Help: "Azure Defender plan enablement time",
+			},
+			[]string{
+				"subscriptionID",
+				"plan",
+			},
+		)
+		m.Collector.RegisterMetricList("defenderPlanEnablementTime", m.prometheus.defenderPlanEnablementTime, true)
+
+		m.prometheus.defenderPlanCompliant = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "azurerm_defender_plan_compliant",
+				Help: "Azure Defender required plan is enabled (standard tier) on subscription",
+			},
+			[]string{
+				"subscriptionID",
+				"plan",
+			},
+		)
+		m.Collector.RegisterMetricList("defenderPlanCompliant", m.prometheus.defenderPlanCompliant, true)
+	}
+
+	if Config.Collectors.Defender.RegulatoryCompliance.Enabled {
+		m.prometheus.defenderRegulatoryComplianceStandardInfo = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "azurerm_defender_regulatory_compliance_standard_info",
+				Help: "Azure Defender regulatory compliance standard",
+			},
+			[]string{
+				"subscriptionID",
+				"standard",
+				"state",
+			},
+		)
+		m.Collector.RegisterMetricList("defenderRegulatoryComplianceStandardInfo", m.prometheus.defenderRegulatoryComplianceStandardInfo, true)
+
+		m.prometheus.defenderRegulatoryComplianceStandardControls = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "azurerm_defender_regulatory_compliance_standard_controls",
+				Help: "Azure Defender regulatory compliance standard count of controls by status",
+			},
+			[]string{
+				"subscriptionID",
+				"standard",
+				"status",
+			},
+		)
+		m.Collector.RegisterMetricList("defenderRegulatoryComplianceStandardControls", m.prometheus.defenderRegulatoryComplianceStandardControls, true)
+
+		m.prometheus.defenderRegulatoryComplianceControlInfo = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "azurerm_defender_regulatory_compliance_control_info",
+				Help: "Azure Defender regulatory compliance control",
+			},
+			[]string{
+				"subscriptionID",
+				"standard",
+				"control",
+				"description",
+				"state",
+			},
+		)
+		m.Collector.RegisterMetricList("defenderRegulatoryComplianceControlInfo", m.prometheus.defenderRegulatoryComplianceControlInfo, true)
+
+		m.prometheus.defenderRegulatoryComplianceControlAssessments = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "azurerm_defender_regulatory_compliance_control_assessments",
+				Help: "Azure Defender regulatory compliance control count of assessments by status",
+			},
+			[]string{
+				"subscriptionID",
+				"standard",
+				"control",
+				"status",
+			},
+		)
+		m.Collector.RegisterMetricList("defenderRegulatoryComplianceControlAssessments", m.prometheus.defenderRegulatoryComplianceControlAssessments, true)
+
+		if Config.Collectors.Defender.RegulatoryCompliance.Assessments {
+			m.prometheus.defenderRegulatoryComplianceAssessmentResources = prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: "azurerm_defender_regulatory_compliance_assessment_resources",
+					Help: "Azure Defender regulatory compliance assessment count of resources by status",
+				},
+				[]string{
+					"subscriptionID",
+					"standard",
+					"control",
+					"assessment",
+					"description",
+					"status",
+				},
+			)
+			m.Collector.RegisterMetricList("defenderRegulatoryComplianceAssessmentResources", m.prometheus.defenderRegulatoryComplianceAssessmentResources, true)
+		}
+	}
 }
 
 func (m *MetricsCollectorAzureRmDefender) Reset() {}
@@ -114,8 +345,21 @@ func (m *MetricsCollectorAzureRmDefender) Reset() {}
 func (m *MetricsCollectorAzureRmDefender) Collect(callback chan<- func()) {
 	err := AzureSubscriptionsIterator.ForEachAsync(m.Logger(), func(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
 		m.collectAzureSecureScore(subscription, logger, callback)
+		m.collectAzureSecureScoreControls(subscription, logger, callback)
 		m.collectAzureSecurityCompliance(subscription, logger, callback)
 		m.collectAzureAdvisorRecommendations(subscription, logger, callback)
+
+		if Config.Collectors.Defender.Assessments.Enabled {
+			m.collectAzureSecurityAssessments(subscription, logger, callback)
+		}
+
+		if Config.Collectors.Defender.Plans.Enabled {
+			m.collectAzureDefenderPlans(subscription, logger, callback)
+		}
+
+		if Config.Collectors.Defender.RegulatoryCompliance.Enabled {
+			m.collectAzureRegulatoryCompliance(subscription, logger, callback)
+		}
 	})
 	if err != nil {
 		panic(err)
@@ -151,6 +395,96 @@ func (m *MetricsCollectorAzureRmDefender) collectAzureSecureScore(subscription *
 	}
 }
 
+// collectAzureSecureScoreControls collects the controls of the secure score (eg. "Enable MFA")
+func (m *MetricsCollectorAzureRmDefender) collectAzureSecureScoreControls(subscription *armsubscriptions.Subscription, logger *slog.Logger, callback chan<- func()) {
+	client, err := armsecurity.NewSecureScoreControlsClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	controlInfoMetrics := m.Collector.GetMetricList("defenderSecureScoreControlInfo")
+	controlPercentageMetrics := m.Collector.GetMetricList("defenderSecureScoreControlPercentage")
+	controlMaxMetrics := m.Collector.GetMetricList("defenderSecureScoreControlMax")
+	controlCurrentMetrics := m.Collector.GetMetricList("defenderSecureScoreControlCurrent")
+	controlResourcesMetrics := m.Collector.GetMetricList("defenderSecureScoreControlResources")
+
+	// controls have no category, the category is taken from the assessments of the control definition
+	assessmentCategories := m.fetchAzureSecurityAssessmentCategories(subscription)
+
+	expand := armsecurity.ExpandControlsEnumDefinition
+	pager := client.NewListPager(&armsecurity.SecureScoreControlsClientListOptions{
+		Expand: &expand,
+	})
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			panic(err)
+		}
+
+		for _, control := range result.Value {
+			if control.Properties == nil {
+				continue
+			}
+
+			// source type of the control definition (eg. builtin or custom)
+			sourceType := ""
+			categories := []string{}
+			if definition := control.Properties.Definition; definition != nil && definition.Properties != nil {
+				if definition.Properties.Source != nil && definition.Properties.Source.SourceType != nil {
+					sourceType = strings.ToLower(string(*definition.Properties.Source.SourceType))
+				}
+
+				for _, assessmentDefinition := range definition.Properties.AssessmentDefinitions {
+					if assessmentDefinition == nil {
+						continue
+					}
+
+					// /providers/Microsoft.Security/assessmentMetadata/{assessmentName}
+					assessmentID := strings.Split(to.StringLower(assessmentDefinition.ID), "/")
+					categories = append(categories, assessmentCategories[assessmentID[len(assessmentID)-1]]...)
+				}
+			}
+			slices.Sort(categories)
+
+			controlInfoMetrics.AddInfo(prometheus.Labels{
+				"subscriptionID": to.StringLower(subscription.SubscriptionID),
+				"controlName":    to.StringLower(control.Name),
+				"displayName":    to.String(control.Properties.DisplayName),
+				"category":       strings.Join(slices.Compact(categories), ","),
+				"sourceType":     sourceType,
+			})
+
+			infoLabels := prometheus.Labels{
+				"subscriptionID": to.StringLower(subscription.SubscriptionID),
+				"controlName":    to.StringLower(control.Name),
+			}
+			if score := control.Properties.Score; score != nil {
+				controlPercentageMetrics.AddIfNotNil(infoLabels, score.Percentage)
+				controlCurrentMetrics.AddIfNotNil(infoLabels, score.Current)
+				if score.Max != nil {
+					controlMaxMetrics.Add(infoLabels, float64(*score.Max))
+				}
+			}
+
+			for status, count := range map[string]*int32{
+				"healthy":       control.Properties.HealthyResourceCount,
+				"unhealthy":     control.Properties.UnhealthyResourceCount,
+				"notApplicable": control.Properties.NotApplicableResourceCount,
+			} {
+				if count == nil {
+					continue
+				}
+
+				controlResourcesMetrics.Add(prometheus.Labels{
+					"subscriptionID": to.StringLower(subscription.SubscriptionID),
+					"controlName":    to.StringLower(control.Name),
+					"status":         status,
+				}, float64(*count))
+			}
+		}
+	}
+}
+
 func (m *MetricsCollectorAzureRmDefender) collectAzureSecurityCompliance(subscription *armsubscriptions.Subscription, logger *slog.Logger, callback chan<- func()) {
 	client, err := armsecurity.NewCompliancesClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
 	if err != nil {
@@ -207,50 +541,50 @@ func (m *MetricsCollectorAzureRmDefender) collectAzureSecurityCompliance(subscri
 }
 
 func (m *MetricsCollectorAzureRmDefender) collectAzureAdvisorRecommendations(subscription *armsubscriptions.Subscription, logger *slog.Logger, callback chan<- func()) {
-	client, err := armadvisor.NewRecommendationsClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
-	if err != nil {
-		panic(err)
-	}
-
 	recommendationMetrics := m.Collector.GetMetricList("defenderAdvisorRecommendations")
 
-	pager := client.NewListPager(nil)
-	for pager.More() {
-		result, err := pager.NextPage(m.Context())
-		if err != nil {
-			panic(err)
+	recommendations := azureAdvisorRecommendations.Fetch(m.Context(), subscription)
+	recommendations = filterAdvisorRecommendationsByCategory(recommendations, Config.Collectors.Defender.Advisor.Categories)
+
+	for _, recommendation := range recommendations {
+		if recommendation.Properties == nil {
+			continue
 		}
 
-		for _, recommendation := range result.Value {
-			resourceId := to.String(recommendation.ID)
-			azureResource, _ := armclient.ParseResourceId(resourceId)
+		resourceId := to.String(recommendation.ID)
+		azureResource, _ := armclient.ParseResourceId(resourceId)
 
-			category := ""
-			if recommendation.Properties.Category != nil {
-				category = strings.ToLower(string(*recommendation.Properties.Category))
-			}
+		category := advisorRecommendationCategory(recommendation)
 
-			risk := ""
-			if recommendation.Properties.Risk != nil {
-				risk = strings.ToLower(string(*recommendation.Properties.Risk))
-			}
+		risk := ""
+		if recommendation.Properties.Risk != nil {
+			risk = strings.ToLower(string(*recommendation.Properties.Risk))
+		}
 
-			impact := ""
-			if recommendation.Properties.Impact != nil {
-				impact = strings.ToLower(string(*recommendation.Properties.Impact))
-			}
+		impact := ""
+		if recommendation.Properties.Impact != nil {
+			impact = strings.ToLower(string(*recommendation.Properties.Impact))
+		}
 
-			infoLabels := prometheus.Labels{
-				"subscriptionID": to.StringLower(subscription.SubscriptionID),
-				"category":       category,
-				"resourceType":   to.StringLower(recommendation.Properties.ImpactedField),
-				"resourceName":   to.StringLower(recommendation.Properties.ImpactedValue),
-				"resourceGroup":  azureResource.ResourceGroup,
-				"problem":        to.String(recommendation.Properties.ShortDescription.Problem),
-				"impact":         impact,
-				"risk":           risk,
-			}
-			recommendationMetrics.Add(infoLabels, 1)
+		problem := ""
+		if recommendation.Properties.ShortDescription != nil {
+			problem = to.String(recommendation.Properties.ShortDescription.Problem)
+		}
+
+		if Config.Collectors.Defender.Advisor.ProblemMaxLength > 0 {
+			problem = truncateStrings(problem, Config.Collectors.Defender.Advisor.ProblemMaxLength, "...")
+		}
+
+		infoLabels := prometheus.Labels{
+			"subscriptionID": to.StringLower(subscription.SubscriptionID),
+			"category":       category,
+			"resourceType":   to.StringLower(recommendation.Properties.ImpactedField),
+			"resourceName":   to.StringLower(recommendation.Properties.ImpactedValue),
+			"resourceGroup":  azureResource.ResourceGroup,
+			"problem":        problem,
+			"impact":         impact,
+			"risk":           risk,
 		}
+		recommendationMetrics.Add(infoLabels, 1)
 	}
 }
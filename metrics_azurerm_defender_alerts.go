@@ -0,0 +1,181 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/security/armsecurity"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/webdevops/go-common/prometheus/collector"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+type MetricsCollectorAzureRmDefenderAlerts struct {
+	collector.Processor
+
+	prometheus struct {
+		defenderAlertInfo      *prometheus.GaugeVec
+		defenderAlertStartTime *prometheus.GaugeVec
+		defenderAlertCount     *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmDefenderAlerts) Setup(collector *collector.Collector) {
+	m.Processor.Setup(collector)
+
+	m.prometheus.defenderAlertInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_defender_alert_info",
+			Help: "Azure Defender active security alert",
+		},
+		[]string{
+			"subscriptionID",
+			"alertName",
+			"alertType",
+			"displayName",
+			"severity",
+			"status",
+			"compromisedEntity",
+			"resourceID",
+		},
+	)
+	m.Collector.RegisterMetricList("defenderAlertInfo", m.prometheus.defenderAlertInfo, true)
+
+	m.prometheus.defenderAlertStartTime = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_defender_alert_start_timestamp_seconds",
+			Help: "Azure Defender active security alert start time",
+		},
+		[]string{
+			"subscriptionID",
+			"alertName",
+		},
+	)
+	m.Collector.RegisterMetricList("defenderAlertStartTime", m.prometheus.defenderAlertStartTime, true)
+
+	m.prometheus.defenderAlertCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_defender_alert_count",
+			Help: "Azure Defender count of security alerts by severity and status (inside look-back window)",
+		},
+		[]string{
+			"subscriptionID",
+			"severity",
+			"status",
+		},
+	)
+	m.Collector.RegisterMetricList("defenderAlertCount", m.prometheus.defenderAlertCount, true)
+}
+
+func (m *MetricsCollectorAzureRmDefenderAlerts) Reset() {}
+
+func (m *MetricsCollectorAzureRmDefenderAlerts) Collect(callback chan<- func()) {
+	err := AzureSubscriptionsIterator.ForEachAsync(m.Logger(), func(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
+		m.collectAzureSecurityAlerts(subscription, logger, callback)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (m *MetricsCollectorAzureRmDefenderAlerts) collectAzureSecurityAlerts(subscription *armsubscriptions.Subscription, logger *slog.Logger, callback chan<- func()) {
+	client, err := armsecurity.NewAlertsClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	infoMetrics := m.Collector.GetMetricList("defenderAlertInfo")
+	startTimeMetrics := m.Collector.GetMetricList("defenderAlertStartTime")
+	countMetrics := m.Collector.GetMetricList("defenderAlertCount")
+
+	lookBackTime := time.Now().Add(-Config.Collectors.DefenderAlerts.GetLookBack())
+
+	counts := map[string]map[string]float64{}
+
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			panic(err)
+		}
+
+		for _, alert := range result.Value {
+			if alert.Properties == nil {
+				continue
+			}
+
+			// alerts outside of look-back window
+			if alert.Properties.StartTimeUTC != nil && alert.Properties.StartTimeUTC.Before(lookBackTime) {
+				continue
+			}
+
+			severity := ""
+			if alert.Properties.Severity != nil {
+				severity = stringToStringLower(string(*alert.Properties.Severity))
+			}
+
+			status := ""
+			if alert.Properties.Status != nil {
+				status = stringToStringLower(string(*alert.Properties.Status))
+			}
+
+			if _, exists := counts[severity]; !exists {
+				counts[severity] = map[string]float64{}
+			}
+			counts[severity][status]++
+
+			if !Config.Collectors.DefenderAlerts.IsSeverityEnabled(severity) {
+				continue
+			}
+
+			// only active alerts (resolved and dismissed alerts are only counted)
+			if alert.Properties.Status == nil {
+				continue
+			}
+			switch *alert.Properties.Status {
+			case armsecurity.AlertStatusActive, armsecurity.AlertStatusInProgress:
+			default:
+				continue
+			}
+
+			infoMetrics.AddInfo(prometheus.Labels{
+				"subscriptionID":    to.StringLower(subscription.SubscriptionID),
+				"alertName":         to.StringLower(alert.Name),
+				"alertType":         to.String(alert.Properties.AlertType),
+				"displayName":       to.String(alert.Properties.AlertDisplayName),
+				"severity":          severity,
+				"status":            status,
+				"compromisedEntity": to.String(alert.Properties.CompromisedEntity),
+				"resourceID":        stringToStringLower(securityAlertResourceId(alert)),
+			})
+
+			if alert.Properties.StartTimeUTC != nil {
+				startTimeMetrics.AddTime(prometheus.Labels{
+					"subscriptionID": to.StringLower(subscription.SubscriptionID),
+					"alertName":      to.StringLower(alert.Name),
+				}, *alert.Properties.StartTimeUTC)
+			}
+		}
+	}
+
+	for severity, statusCounts := range counts {
+		for status, count := range statusCounts {
+			countMetrics.Add(prometheus.Labels{
+				"subscriptionID": to.StringLower(subscription.SubscriptionID),
+				"severity":       severity,
+				"status":         status,
+			}, count)
+		}
+	}
+}
+
+// securityAlertResourceId returns the azure resource id of the alerted resource (if available)
+func securityAlertResourceId(alert *armsecurity.Alert) string {
+	for _, identifier := range alert.Properties.ResourceIdentifiers {
+		if azureIdentifier, ok := identifier.(*armsecurity.AzureResourceIdentifier); ok && azureIdentifier.AzureResourceID != nil {
+			return *azureIdentifier.AzureResourceID
+		}
+	}
+	return ""
+}
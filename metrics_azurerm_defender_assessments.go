@@ -0,0 +1,167 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/security/armsecurity"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+// collectAzureSecurityAssessments collects the security assessments of all resources inside the subscription
+func (m *MetricsCollectorAzureRmDefender) collectAzureSecurityAssessments(subscription *armsubscriptions.Subscription, logger *slog.Logger, callback chan<- func()) {
+	client, err := armsecurity.NewAssessmentsClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	severityMap := m.fetchAzureSecurityAssessmentSeverities(subscription)
+
+	unhealthyMetrics := m.Collector.GetMetricList("defenderAssessmentUnhealthy")
+	countMetrics := m.Collector.GetMetricList("defenderAssessmentCount")
+
+	counts := map[string]map[string]float64{}
+
+	pager := client.NewListPager(*subscription.ID, nil)
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			panic(err)
+		}
+
+		for _, assessment := range result.Value {
+			if assessment.Properties == nil || assessment.Properties.Status == nil {
+				continue
+			}
+
+			assessmentName := to.StringLower(assessment.Name)
+			status := ""
+			if assessment.Properties.Status.Code != nil {
+				status = stringToStringLower(string(*assessment.Properties.Status.Code))
+			}
+
+			severity := severityMap[assessmentName]
+			if assessment.Properties.Metadata != nil && assessment.Properties.Metadata.Severity != nil {
+				severity = stringToStringLower(string(*assessment.Properties.Metadata.Severity))
+			}
+
+			if _, exists := counts[severity]; !exists {
+				counts[severity] = map[string]float64{}
+			}
+			counts[severity][status]++
+
+			if status != stringToStringLower(string(armsecurity.AssessmentStatusCodeUnhealthy)) {
+				continue
+			}
+
+			if !Config.Collectors.Defender.IsAssessmentSeverityEnabled(severity) {
+				continue
+			}
+
+			displayName := to.String(assessment.Properties.DisplayName)
+			if !Config.Collectors.Defender.IsAssessmentEnabled(assessmentName, displayName) {
+				continue
+			}
+
+			resourceID := securityAssessmentResourceId(assessment)
+
+			infoLabels := prometheus.Labels{
+				"subscriptionID": to.StringLower(subscription.SubscriptionID),
+				"resourceID":     stringToStringLower(resourceID),
+				"assessmentName": assessmentName,
+				"displayName":    displayName,
+				"severity":       severity,
+				"statusCause":    to.String(assessment.Properties.Status.Cause),
+			}
+			infoLabels = AzureResourceTagManager.AddResourceTagsToPrometheusLabels(m.Context(), infoLabels, resourceID)
+			unhealthyMetrics.Add(infoLabels, 1)
+		}
+	}
+
+	for severity, statusCounts := range counts {
+		for status, count := range statusCounts {
+			countMetrics.Add(prometheus.Labels{
+				"subscriptionID": to.StringLower(subscription.SubscriptionID),
+				"severity":       severity,
+				"status":         status,
+			}, count)
+		}
+	}
+}
+
+// fetchAzureSecurityAssessmentSeverities returns the severity of all assessments (by assessment name) from the assessment metadata
+func (m *MetricsCollectorAzureRmDefender) fetchAzureSecurityAssessmentSeverities(subscription *armsubscriptions.Subscription) map[string]string {
+	client, err := armsecurity.NewAssessmentsMetadataClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	ret := map[string]string{}
+
+	pager := client.NewListBySubscriptionPager(nil)
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			panic(err)
+		}
+
+		for _, metadata := range result.Value {
+			if metadata.Properties == nil || metadata.Properties.Severity == nil {
+				continue
+			}
+
+			ret[to.StringLower(metadata.Name)] = stringToStringLower(string(*metadata.Properties.Severity))
+		}
+	}
+
+	return ret
+}
+
+// fetchAzureSecurityAssessmentCategories returns the categories (eg. compute, networking) of all assessments (by assessment name) from the assessment metadata
+func (m *MetricsCollectorAzureRmDefender) fetchAzureSecurityAssessmentCategories(subscription *armsubscriptions.Subscription) map[string][]string {
+	client, err := armsecurity.NewAssessmentsMetadataClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	ret := map[string][]string{}
+
+	pager := client.NewListBySubscriptionPager(nil)
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			panic(err)
+		}
+
+		for _, metadata := range result.Value {
+			if metadata.Properties == nil {
+				continue
+			}
+
+			for _, category := range metadata.Properties.Categories {
+				if category != nil {
+					ret[to.StringLower(metadata.Name)] = append(ret[to.StringLower(metadata.Name)], stringToStringLower(string(*category)))
+				}
+			}
+		}
+	}
+
+	return ret
+}
+
+// securityAssessmentResourceId returns the resource id of the assessed resource
+func securityAssessmentResourceId(assessment *armsecurity.AssessmentResponse) string {
+	if details, ok := assessment.Properties.ResourceDetails.(*armsecurity.AzureResourceDetails); ok && details.ID != nil {
+		return *details.ID
+	}
+
+	// assessment id: {resourceId}/providers/Microsoft.Security/assessments/{assessmentName}
+	assessmentID := to.String(assessment.ID)
+	if pos := strings.Index(strings.ToLower(assessmentID), "/providers/microsoft.security/assessments/"); pos >= 0 {
+		return assessmentID[:pos]
+	}
+
+	return ""
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/security/armsecurity"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+// collectAzureDefenderPlans collects the defender plans (pricings) of the subscription and checks the required plans
+func (m *MetricsCollectorAzureRmDefender) collectAzureDefenderPlans(subscription *armsubscriptions.Subscription, logger *slog.Logger, callback chan<- func()) {
+	client, err := armsecurity.NewPricingsClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	infoMetrics := m.Collector.GetMetricList("defenderPlanInfo")
+	enablementTimeMetrics := m.Collector.GetMetricList("defenderPlanEnablementTime")
+	compliantMetrics := m.Collector.GetMetricList("defenderPlanCompliant")
+
+	result, err := client.List(m.Context(), *subscription.ID, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	// enabled (standard tier) plans
+	enabledPlans := map[string]bool{}
+
+	for _, pricing := range result.Value {
+		if pricing.Properties == nil {
+			continue
+		}
+
+		plan := to.StringLower(pricing.Name)
+
+		pricingTier := ""
+		if pricing.Properties.PricingTier != nil {
+			pricingTier = stringToStringLower(string(*pricing.Properties.PricingTier))
+			enabledPlans[plan] = *pricing.Properties.PricingTier == armsecurity.PricingTierStandard
+		}
+
+		infoMetrics.AddInfo(prometheus.Labels{
+			"subscriptionID": to.StringLower(subscription.SubscriptionID),
+			"plan":           plan,
+			"pricingTier":    pricingTier,
+			"subPlan":        to.StringLower(pricing.Properties.SubPlan),
+		})
+
+		if pricing.Properties.EnablementTime != nil {
+			enablementTimeMetrics.AddTime(prometheus.Labels{
+				"subscriptionID": to.StringLower(subscription.SubscriptionID),
+				"plan":           plan,
+			}, *pricing.Properties.EnablementTime)
+		}
+	}
+
+	for _, requiredPlan := range Config.Collectors.Defender.Plans.Required {
+		plan := strings.ToLower(requiredPlan)
+
+		compliantMetrics.AddBool(prometheus.Labels{
+			"subscriptionID": to.StringLower(subscription.SubscriptionID),
+			"plan":           plan,
+		}, enabledPlans[plan])
+	}
+}
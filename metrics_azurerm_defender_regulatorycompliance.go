@@ -0,0 +1,190 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/security/armsecurity"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+// collectAzureRegulatoryCompliance collects the regulatory compliance standards (eg. Microsoft cloud security benchmark, ISO 27001, PCI DSS)
+// including the controls and (optional) the assessments of the controls
+func (m *MetricsCollectorAzureRmDefender) collectAzureRegulatoryCompliance(subscription *armsubscriptions.Subscription, logger *slog.Logger, callback chan<- func()) {
+	client, err := armsecurity.NewRegulatoryComplianceStandardsClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	standardInfoMetrics := m.Collector.GetMetricList("defenderRegulatoryComplianceStandardInfo")
+	standardControlsMetrics := m.Collector.GetMetricList("defenderRegulatoryComplianceStandardControls")
+
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			if isAzureUnsupportedScopeError(err) {
+				// regulatory compliance is only available with enabled defender plans
+				logger.Warn(`unable to fetch regulatory compliance standards`, slog.Any("error", err))
+				return
+			}
+			panic(err)
+		}
+
+		for _, standard := range result.Value {
+			if standard.Properties == nil {
+				continue
+			}
+
+			standardName := to.String(standard.Name)
+			if !Config.Collectors.Defender.IsRegulatoryComplianceStandardEnabled(standardName) {
+				continue
+			}
+
+			state := ""
+			if standard.Properties.State != nil {
+				state = stringToStringLower(string(*standard.Properties.State))
+			}
+
+			standardInfoMetrics.AddInfo(prometheus.Labels{
+				"subscriptionID": to.StringLower(subscription.SubscriptionID),
+				"standard":       stringToStringLower(standardName),
+				"state":          state,
+			})
+
+			for status, count := range map[string]*int32{
+				"passed":      standard.Properties.PassedControls,
+				"failed":      standard.Properties.FailedControls,
+				"skipped":     standard.Properties.SkippedControls,
+				"unsupported": standard.Properties.UnsupportedControls,
+			} {
+				if count == nil {
+					continue
+				}
+
+				standardControlsMetrics.Add(prometheus.Labels{
+					"subscriptionID": to.StringLower(subscription.SubscriptionID),
+					"standard":       stringToStringLower(standardName),
+					"status":         status,
+				}, float64(*count))
+			}
+
+			// unsupported standards don't provide any controls
+			if standard.Properties.State != nil && *standard.Properties.State == armsecurity.StateUnsupported {
+				continue
+			}
+
+			m.collectAzureRegulatoryComplianceControls(subscription, logger, standardName)
+		}
+	}
+}
+
+func (m *MetricsCollectorAzureRmDefender) collectAzureRegulatoryComplianceControls(subscription *armsubscriptions.Subscription, logger *slog.Logger, standardName string) {
+	client, err := armsecurity.NewRegulatoryComplianceControlsClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	controlInfoMetrics := m.Collector.GetMetricList("defenderRegulatoryComplianceControlInfo")
+	controlAssessmentsMetrics := m.Collector.GetMetricList("defenderRegulatoryComplianceControlAssessments")
+
+	pager := client.NewListPager(standardName, nil)
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			panic(err)
+		}
+
+		for _, control := range result.Value {
+			if control.Properties == nil {
+				continue
+			}
+
+			controlName := to.String(control.Name)
+
+			state := ""
+			if control.Properties.State != nil {
+				state = stringToStringLower(string(*control.Properties.State))
+			}
+
+			controlInfoMetrics.AddInfo(prometheus.Labels{
+				"subscriptionID": to.StringLower(subscription.SubscriptionID),
+				"standard":       stringToStringLower(standardName),
+				"control":        stringToStringLower(controlName),
+				"description":    to.String(control.Properties.Description),
+				"state":          state,
+			})
+
+			for status, count := range map[string]*int32{
+				"passed":  control.Properties.PassedAssessments,
+				"failed":  control.Properties.FailedAssessments,
+				"skipped": control.Properties.SkippedAssessments,
+			} {
+				if count == nil {
+					continue
+				}
+
+				controlAssessmentsMetrics.Add(prometheus.Labels{
+					"subscriptionID": to.StringLower(subscription.SubscriptionID),
+					"standard":       stringToStringLower(standardName),
+					"control":        stringToStringLower(controlName),
+					"status":         status,
+				}, float64(*count))
+			}
+
+			// one request per control and subscription (dozens to hundreds per standard), limited by the standards filter
+			if Config.Collectors.Defender.RegulatoryCompliance.Assessments {
+				// unsupported controls don't provide any assessments
+				if control.Properties.State != nil && *control.Properties.State == armsecurity.StateUnsupported {
+					continue
+				}
+
+				m.collectAzureRegulatoryComplianceAssessments(subscription, logger, standardName, controlName)
+			}
+		}
+	}
+}
+
+func (m *MetricsCollectorAzureRmDefender) collectAzureRegulatoryComplianceAssessments(subscription *armsubscriptions.Subscription, logger *slog.Logger, standardName, controlName string) {
+	client, err := armsecurity.NewRegulatoryComplianceAssessmentsClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	assessmentResourcesMetrics := m.Collector.GetMetricList("defenderRegulatoryComplianceAssessmentResources")
+
+	pager := client.NewListPager(standardName, controlName, nil)
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			panic(err)
+		}
+
+		for _, assessment := range result.Value {
+			if assessment.Properties == nil {
+				continue
+			}
+
+			for status, count := range map[string]*int32{
+				"passed":      assessment.Properties.PassedResources,
+				"failed":      assessment.Properties.FailedResources,
+				"skipped":     assessment.Properties.SkippedResources,
+				"unsupported": assessment.Properties.UnsupportedResources,
+			} {
+				if count == nil {
+					continue
+				}
+
+				assessmentResourcesMetrics.Add(prometheus.Labels{
+					"subscriptionID": to.StringLower(subscription.SubscriptionID),
+					"standard":       stringToStringLower(standardName),
+					"control":        stringToStringLower(controlName),
+					"assessment":     to.StringLower(assessment.Name),
+					"description":    to.String(assessment.Properties.Description),
+					"status":         status,
+				}, float64(*count))
+			}
+		}
+	}
+}
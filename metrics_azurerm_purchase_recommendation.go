@@ -0,0 +1,413 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/consumption/armconsumption"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/webdevops/go-common/azuresdk/armclient"
+	"github.com/webdevops/go-common/prometheus/collector"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+const (
+	PurchaseRecommendationBenefitApiVersion = "2023-08-01"
+)
+
+type (
+	MetricsCollectorAzureRmPurchaseRecommendation struct {
+		collector.Processor
+
+		prometheus struct {
+			reservationQuantity           *prometheus.GaugeVec
+			reservationQuantityNormalized *prometheus.GaugeVec
+			reservationNetSavings         *prometheus.GaugeVec
+			reservationCostWithout        *prometheus.GaugeVec
+			reservationCostWith           *prometheus.GaugeVec
+
+			savingsPlanCommitmentAmount *prometheus.GaugeVec
+			savingsPlanNetSavings       *prometheus.GaugeVec
+			savingsPlanCostWithout      *prometheus.GaugeVec
+			savingsPlanCostWith         *prometheus.GaugeVec
+			savingsPlanCoverage         *prometheus.GaugeVec
+			savingsPlanUtilization      *prometheus.GaugeVec
+		}
+	}
+
+	// benefitRecommendationList is the response of the benefit recommendations api
+	// (not available in armcostmanagement v1)
+	benefitRecommendationList struct {
+		Value    []benefitRecommendation `json:"value"`
+		NextLink *string                 `json:"nextLink"`
+	}
+
+	benefitRecommendation struct {
+		ID         *string `json:"id"`
+		Name       *string `json:"name"`
+		Kind       *string `json:"kind"`
+		Properties *struct {
+			CommitmentGranularity *string  `json:"commitmentGranularity"`
+			CostWithoutBenefit    *float64 `json:"costWithoutBenefit"`
+			CurrencyCode          *string  `json:"currencyCode"`
+			LookBackPeriod        *string  `json:"lookBackPeriod"`
+			Term                  *string  `json:"term"`
+			Scope                 *string  `json:"scope"`
+			SubscriptionID        *string  `json:"subscriptionId"`
+
+			RecommendationDetails *struct {
+				AverageUtilizationPercentage *float64 `json:"averageUtilizationPercentage"`
+				CommitmentAmount             *float64 `json:"commitmentAmount"`
+				CoveragePercentage           *float64 `json:"coveragePercentage"`
+				SavingsAmount                *float64 `json:"savingsAmount"`
+				TotalCost                    *float64 `json:"totalCost"`
+			} `json:"recommendationDetails"`
+		} `json:"properties"`
+	}
+)
+
+func (m *MetricsCollectorAzureRmPurchaseRecommendation) Setup(collector *collector.Collector) {
+	m.Processor.Setup(collector)
+
+	// ----------------------------------------------------
+	// Reservation recommendations
+
+	reservationLabels := []string{
+		"scope",
+		"subscriptionID",
+		"location",
+		"skuName",
+		"resourceType",
+		"term",
+		"lookBackPeriod",
+		"scopeType",
+		"currency",
+	}
+
+	m.prometheus.reservationQuantity = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_recommendation_quantity",
+			Help: "Azure ResourceManager Reservation purchase recommendation quantity",
+		},
+		reservationLabels,
+	)
+	m.Collector.RegisterMetricList("reservationQuantity", m.prometheus.reservationQuantity, true)
+
+	m.prometheus.reservationQuantityNormalized = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_recommendation_quantity_normalized",
+			Help: "Azure ResourceManager Reservation purchase recommendation quantity normalized to the instance flexibility group",
+		},
+		reservationLabels,
+	)
+	m.Collector.RegisterMetricList("reservationQuantityNormalized", m.prometheus.reservationQuantityNormalized, true)
+
+	m.prometheus.reservationNetSavings = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_recommendation_net_savings",
+			Help: "Azure ResourceManager Reservation purchase recommendation expected net savings",
+		},
+		reservationLabels,
+	)
+	m.Collector.RegisterMetricList("reservationNetSavings", m.prometheus.reservationNetSavings, true)
+
+	m.prometheus.reservationCostWithout = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_recommendation_cost_without_reservation",
+			Help: "Azure ResourceManager Reservation purchase recommendation costs without reservation",
+		},
+		reservationLabels,
+	)
+	m.Collector.RegisterMetricList("reservationCostWithout", m.prometheus.reservationCostWithout, true)
+
+	m.prometheus.reservationCostWith = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_recommendation_cost_with_reservation",
+			Help: "Azure ResourceManager Reservation purchase recommendation total costs with reservation",
+		},
+		reservationLabels,
+	)
+	m.Collector.RegisterMetricList("reservationCostWith", m.prometheus.reservationCostWith, true)
+
+	// ----------------------------------------------------
+	// Savings plan recommendations
+
+	savingsPlanLabels := []string{
+		"scope",
+		"subscriptionID",
+		"term",
+		"lookBackPeriod",
+		"scopeType",
+		"commitmentGranularity",
+		"currency",
+	}
+
+	m.prometheus.savingsPlanCommitmentAmount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_recommendation_commitment_amount",
+			Help: "Azure ResourceManager SavingsPlan purchase recommendation commitment amount (per commitment granularity)",
+		},
+		savingsPlanLabels,
+	)
+	m.Collector.RegisterMetricList("savingsPlanCommitmentAmount", m.prometheus.savingsPlanCommitmentAmount, true)
+
+	m.prometheus.savingsPlanNetSavings = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_recommendation_net_savings",
+			Help: "Azure ResourceManager SavingsPlan purchase recommendation expected net savings",
+		},
+		savingsPlanLabels,
+	)
+	m.Collector.RegisterMetricList("savingsPlanNetSavings", m.prometheus.savingsPlanNetSavings, true)
+
+	m.prometheus.savingsPlanCostWithout = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_recommendation_cost_without_benefit",
+			Help: "Azure ResourceManager SavingsPlan purchase recommendation costs without savings plan",
+		},
+		savingsPlanLabels,
+	)
+	m.Collector.RegisterMetricList("savingsPlanCostWithout", m.prometheus.savingsPlanCostWithout, true)
+
+	m.prometheus.savingsPlanCostWith = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_recommendation_cost_with_benefit",
+			Help: "Azure ResourceManager SavingsPlan purchase recommendation total costs with savings plan",
+		},
+		savingsPlanLabels,
+	)
+	m.Collector.RegisterMetricList("savingsPlanCostWith", m.prometheus.savingsPlanCostWith, true)
+
+	m.prometheus.savingsPlanCoverage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_recommendation_coverage",
+			Help: "Azure ResourceManager SavingsPlan purchase recommendation coverage percentage",
+		},
+		savingsPlanLabels,
+	)
+	m.Collector.RegisterMetricList("savingsPlanCoverage", m.prometheus.savingsPlanCoverage, true)
+
+	m.prometheus.savingsPlanUtilization = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_recommendation_utilization",
+			Help: "Azure ResourceManager SavingsPlan purchase recommendation average utilization percentage",
+		},
+		savingsPlanLabels,
+	)
+	m.Collector.RegisterMetricList("savingsPlanUtilization", m.prometheus.savingsPlanUtilization, true)
+}
+
+func (m *MetricsCollectorAzureRmPurchaseRecommendation) Reset() {}
+
+func (m *MetricsCollectorAzureRmPurchaseRecommendation) Collect(callback chan<- func()) {
+	if len(Config.Collectors.PurchaseRecommendation.Scopes) > 0 {
+		for _, scope := range Config.Collectors.PurchaseRecommendation.Scopes {
+			m.collectPurchaseRecommendations(m.Logger().With(slog.String("scope", scope)), scope)
+		}
+	} else {
+		err := AzureSubscriptionsIterator.ForEach(m.Logger(), func(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
+			m.collectPurchaseRecommendations(logger, *subscription.ID)
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// collectPurchaseRecommendations collects the reservation and savings plan recommendations of scope per look back period
+func (m *MetricsCollectorAzureRmPurchaseRecommendation) collectPurchaseRecommendations(logger *slog.Logger, scope string) {
+	for _, lookBackPeriod := range Config.Collectors.PurchaseRecommendation.GetLookBackPeriods() {
+		if Config.Collectors.PurchaseRecommendation.IsReservationEnabled() {
+			for _, resourceType := range Config.Collectors.PurchaseRecommendation.GetReservationResourceTypes() {
+				for _, scopeType := range Config.Collectors.PurchaseRecommendation.GetReservationScopeTypes() {
+					m.collectReservationRecommendations(logger, scope, lookBackPeriod, resourceType, scopeType)
+				}
+			}
+		}
+
+		if Config.Collectors.PurchaseRecommendation.IsSavingsPlanEnabled() {
+			for _, term := range Config.Collectors.PurchaseRecommendation.GetSavingsPlanTerms() {
+				m.collectSavingsPlanRecommendations(logger, scope, lookBackPeriod, term)
+			}
+		}
+	}
+}
+
+func (m *MetricsCollectorAzureRmPurchaseRecommendation) collectReservationRecommendations(logger *slog.Logger, scope, lookBackPeriod, resourceType, scopeType string) {
+	quantityMetric := m.Collector.GetMetricList("reservationQuantity")
+	quantityNormalizedMetric := m.Collector.GetMetricList("reservationQuantityNormalized")
+	netSavingsMetric := m.Collector.GetMetricList("reservationNetSavings")
+	costWithoutMetric := m.Collector.GetMetricList("reservationCostWithout")
+	costWithMetric := m.Collector.GetMetricList("reservationCostWith")
+
+	client, err := armconsumption.NewReservationRecommendationsClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	filter := fmt.Sprintf(
+		`properties/scope eq '%v' AND properties/resourceType eq '%v' AND properties/lookBackPeriod eq '%v'`,
+		scopeType,
+		resourceType,
+		lookBackPeriod,
+	)
+
+	pager := client.NewListPager(scope, &armconsumption.ReservationRecommendationsClientListOptions{
+		Filter: to.Ptr(filter),
+	})
+
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			if isAzureUnsupportedScopeError(err) {
+				// reservation recommendations are not supported for every resource type and scope
+				logger.Warn(`unable to fetch reservation recommendations`, slog.String("resourceType", resourceType), slog.String("scopeType", scopeType), slog.Any("error", err))
+				return
+			}
+			panic(err)
+		}
+
+		for _, row := range result.Value {
+			labels := prometheus.Labels{
+				"scope":          scope,
+				"subscriptionID": "",
+				"location":       "",
+				"skuName":        "",
+				"resourceType":   stringToStringLower(resourceType),
+				"term":           "",
+				"lookBackPeriod": stringToStringLower(lookBackPeriod),
+				"scopeType":      stringToStringLower(scopeType),
+				"currency":       "",
+			}
+
+			var quantity, quantityNormalized, netSavings, costWithout, costWith *float64
+
+			switch recommendation := row.(type) {
+			case *armconsumption.LegacyReservationRecommendation:
+				if recommendation.Properties == nil {
+					continue
+				}
+
+				if singleScopeProperties, ok := recommendation.Properties.(*armconsumption.LegacySingleScopeReservationRecommendationProperties); ok {
+					labels["subscriptionID"] = to.StringLower(singleScopeProperties.SubscriptionID)
+				}
+
+				properties := recommendation.Properties.GetLegacyReservationRecommendationProperties()
+				labels["location"] = to.StringLower(recommendation.Location)
+				labels["skuName"] = to.String(recommendation.SKU)
+				labels["term"] = to.StringLower(properties.Term)
+
+				quantity = properties.RecommendedQuantity
+				if properties.RecommendedQuantityNormalized != nil {
+					quantityNormalized = to.Ptr(float64(*properties.RecommendedQuantityNormalized))
+				}
+				netSavings = properties.NetSavings
+				costWithout = properties.CostWithNoReservedInstances
+				costWith = properties.TotalCostWithReservedInstances
+			case *armconsumption.ModernReservationRecommendation:
+				if recommendation.Properties == nil {
+					continue
+				}
+
+				properties := recommendation.Properties
+				labels["location"] = to.StringLower(properties.Location)
+				labels["skuName"] = to.String(properties.SKUName)
+				labels["term"] = to.StringLower(properties.Term)
+
+				quantity = properties.RecommendedQuantity
+				if properties.RecommendedQuantityNormalized != nil {
+					quantityNormalized = to.Ptr(float64(*properties.RecommendedQuantityNormalized))
+				}
+
+				for _, amount := range []*armconsumption.Amount{properties.NetSavings, properties.CostWithNoReservedInstances, properties.TotalCostWithReservedInstances} {
+					if amount != nil && amount.Currency != nil {
+						labels["currency"] = to.StringLower(amount.Currency)
+					}
+				}
+
+				if properties.NetSavings != nil {
+					netSavings = properties.NetSavings.Value
+				}
+				if properties.CostWithNoReservedInstances != nil {
+					costWithout = properties.CostWithNoReservedInstances.Value
+				}
+				if properties.TotalCostWithReservedInstances != nil {
+					costWith = properties.TotalCostWithReservedInstances.Value
+				}
+			default:
+				logger.Debug(`unknown reservation recommendation kind, skipping`)
+				continue
+			}
+
+			if labels["subscriptionID"] == "" && strings.EqualFold(scopeType, "Single") {
+				if azureResource, err := armclient.ParseResourceId(scope); err == nil {
+					labels["subscriptionID"] = azureResource.Subscription
+				}
+			}
+
+			quantityMetric.AddIfNotNil(labels, quantity)
+			quantityNormalizedMetric.AddIfNotNil(labels, quantityNormalized)
+			netSavingsMetric.AddIfNotNil(labels, netSavings)
+			costWithoutMetric.AddIfNotNil(labels, costWithout)
+			costWithMetric.AddIfNotNil(labels, costWith)
+		}
+	}
+}
+
+func (m *MetricsCollectorAzureRmPurchaseRecommendation) collectSavingsPlanRecommendations(logger *slog.Logger, scope, lookBackPeriod, term string) {
+	commitmentAmountMetric := m.Collector.GetMetricList("savingsPlanCommitmentAmount")
+	netSavingsMetric := m.Collector.GetMetricList("savingsPlanNetSavings")
+	costWithoutMetric := m.Collector.GetMetricList("savingsPlanCostWithout")
+	costWithMetric := m.Collector.GetMetricList("savingsPlanCostWith")
+	coverageMetric := m.Collector.GetMetricList("savingsPlanCoverage")
+	utilizationMetric := m.Collector.GetMetricList("savingsPlanUtilization")
+
+	requestUrl := armRestUrl(
+		scope+"/providers/Microsoft.CostManagement/benefitRecommendations",
+		url.Values{
+			"api-version": {PurchaseRecommendationBenefitApiVersion},
+			"$filter":     {fmt.Sprintf(`properties/lookBackPeriod eq '%v' AND properties/term eq '%v'`, lookBackPeriod, term)},
+		},
+	)
+
+	for requestUrl != "" {
+		result := benefitRecommendationList{}
+		if statusCode, err := armRestGet(m.Context(), requestUrl, &result); err != nil {
+			if isAzureUnsupportedScopeStatusCode(statusCode) {
+				// benefit recommendations are not supported on every scope
+				logger.Warn(`unable to fetch savings plan recommendations`, slog.Int("statusCode", statusCode), slog.Any("error", err))
+				return
+			}
+			panic(err)
+		}
+
+		for _, recommendation := range result.Value {
+			properties := recommendation.Properties
+			if properties == nil || properties.RecommendationDetails == nil {
+				continue
+			}
+
+			labels := prometheus.Labels{
+				"scope":                 scope,
+				"subscriptionID":        to.StringLower(properties.SubscriptionID),
+				"term":                  stringToStringLower(term),
+				"lookBackPeriod":        stringToStringLower(lookBackPeriod),
+				"scopeType":             to.StringLower(properties.Scope),
+				"commitmentGranularity": to.StringLower(properties.CommitmentGranularity),
+				"currency":              to.StringLower(properties.CurrencyCode),
+			}
+
+			commitmentAmountMetric.AddIfNotNil(labels, properties.RecommendationDetails.CommitmentAmount)
+			netSavingsMetric.AddIfNotNil(labels, properties.RecommendationDetails.SavingsAmount)
+			costWithoutMetric.AddIfNotNil(labels, properties.CostWithoutBenefit)
+			costWithMetric.AddIfNotNil(labels, properties.RecommendationDetails.TotalCost)
+			coverageMetric.AddIfNotNil(labels, properties.RecommendationDetails.CoveragePercentage)
+			utilizationMetric.AddIfNotNil(labels, properties.RecommendationDetails.AverageUtilizationPercentage)
+		}
+
+		requestUrl = to.String(result.NextLink)
+	}
+}
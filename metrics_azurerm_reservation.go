@@ -1,29 +1,52 @@
 package main
 
 import (
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/consumption/armconsumption"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/reservations/armreservations/v3"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/webdevops/go-common/prometheus/collector"
 	"github.com/webdevops/go-common/utils/to"
 )
 
-// Define MetricsCollectorAzureRmReservation struct
-type MetricsCollectorAzureRmReservation struct {
-	collector.Processor
+const (
+	// ReservationDetailsLatestLookbackDays is the number of complete days fetched in latest mode
+	// to find the last day with reservation details, usage data is often not settled for yesterday
+	ReservationDetailsLatestLookbackDays = 7
+)
+
+type (
+	// Define MetricsCollectorAzureRmReservation struct
+	MetricsCollectorAzureRmReservation struct {
+		collector.Processor
+
+		prometheus struct {
+			reservationInfo                  *prometheus.GaugeVec
+			reservationUsage                 *prometheus.GaugeVec
+			reservationMinUsage              *prometheus.GaugeVec
+			reservationMaxUsage              *prometheus.GaugeVec
+			reservationUsedHours             *prometheus.GaugeVec
+			reservationReservedHours         *prometheus.GaugeVec
+			reservationTotalReservedQuantity *prometheus.GaugeVec
+			reservationAverageUsage          *prometheus.GaugeVec
 
-	prometheus struct {
-		reservationInfo                  *prometheus.GaugeVec
-		reservationUsage                 *prometheus.GaugeVec
-		reservationMinUsage              *prometheus.GaugeVec
-		reservationMaxUsage              *prometheus.GaugeVec
-		reservationUsedHours             *prometheus.GaugeVec
-		reservationReservedHours         *prometheus.GaugeVec
-		reservationTotalReservedQuantity *prometheus.GaugeVec
+			detailsUsedHours     *prometheus.GaugeVec
+			detailsReservedHours *prometheus.GaugeVec
+
+			inventoryInfo      *prometheus.GaugeVec
+			inventoryExpiry    *prometheus.GaugeVec
+			inventoryEffective *prometheus.GaugeVec
+			inventoryQuantity  *prometheus.GaugeVec
+			inventoryRenew     *prometheus.GaugeVec
+		}
 	}
-}
+)
 
 // Setup method to initialize Prometheus metrics
 func (m *MetricsCollectorAzureRmReservation) Setup(collector *collector.Collector) {
@@ -35,7 +58,11 @@ func (m *MetricsCollectorAzureRmReservation) Setup(collector *collector.Collecto
 		"reservationID",
 		"skuName",
 		"kind",
-		"usageDate",
+	}
+
+	// latest mode only exports the latest complete grain
+	if !Config.Collectors.Reservation.IsLatestMode() {
+		commonLabels = append(commonLabels, "usageDate")
 	}
 
 	m.prometheus.reservationInfo = prometheus.NewGaugeVec(
@@ -100,6 +127,121 @@ func (m *MetricsCollectorAzureRmReservation) Setup(collector *collector.Collecto
 		commonLabels,
 	)
 	m.Collector.RegisterMetricList("reservationTotalReservedQuantity", m.prometheus.reservationTotalReservedQuantity, true)
+
+	m.prometheus.reservationAverageUsage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_utilization_average",
+			Help: "Azure ResourceManager Reservation average Utilization over window",
+		},
+		[]string{
+			"scope",
+			"reservationOrderID",
+			"reservationID",
+			"skuName",
+			"kind",
+			"window",
+		},
+	)
+	m.Collector.RegisterMetricList("reservationAverageUsage", m.prometheus.reservationAverageUsage, true)
+
+	// ----------------------------------------------------
+	// Details
+
+	detailLabels := []string{
+		"scope",
+		"reservationOrderID",
+		"reservationID",
+		"skuName",
+		"kind",
+		"instanceID",
+		"instanceFlexibilityGroup",
+		"instanceFlexibilityRatio",
+	}
+
+	if !Config.Collectors.Reservation.IsLatestMode() {
+		detailLabels = append(detailLabels, "usageDate")
+	}
+
+	m.prometheus.detailsUsedHours = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_details_used_hours",
+			Help: "Azure ResourceManager Reservation Used Hours per resource",
+		},
+		detailLabels,
+	)
+	m.Collector.RegisterMetricList("detailsUsedHours", m.prometheus.detailsUsedHours, true)
+
+	m.prometheus.detailsReservedHours = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_details_reserved_hours",
+			Help: "Azure ResourceManager Reservation Reserved Hours per resource",
+		},
+		detailLabels,
+	)
+	m.Collector.RegisterMetricList("detailsReservedHours", m.prometheus.detailsReservedHours, true)
+
+	// ----------------------------------------------------
+	// Inventory
+
+	inventoryLabels := []string{
+		"reservationOrderID",
+		"reservationID",
+	}
+
+	m.prometheus.inventoryInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_inventory_info",
+			Help: "Azure ResourceManager Reservation inventory information",
+		},
+		[]string{
+			"reservationOrderID",
+			"reservationID",
+			"displayName",
+			"skuName",
+			"location",
+			"reservedResourceType",
+			"term",
+			"appliedScopeType",
+			"provisioningState",
+		},
+	)
+	m.Collector.RegisterMetricList("inventoryInfo", m.prometheus.inventoryInfo, true)
+
+	m.prometheus.inventoryExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_inventory_expiry_timestamp_seconds",
+			Help: "Azure ResourceManager Reservation expiry timestamp",
+		},
+		inventoryLabels,
+	)
+	m.Collector.RegisterMetricList("inventoryExpiry", m.prometheus.inventoryExpiry, true)
+
+	m.prometheus.inventoryEffective = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_inventory_effective_timestamp_seconds",
+			Help: "Azure ResourceManager Reservation effective timestamp",
+		},
+		inventoryLabels,
+	)
+	m.Collector.RegisterMetricList("inventoryEffective", m.prometheus.inventoryEffective, true)
+
+	m.prometheus.inventoryQuantity = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_inventory_quantity",
+			Help: "Azure ResourceManager Reservation quantity",
+		},
+		inventoryLabels,
+	)
+	m.Collector.RegisterMetricList("inventoryQuantity", m.prometheus.inventoryQuantity, true)
+
+	m.prometheus.inventoryRenew = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_inventory_renew",
+			Help: "Azure ResourceManager Reservation auto renew status",
+		},
+		inventoryLabels,
+	)
+	m.Collector.RegisterMetricList("inventoryRenew", m.prometheus.inventoryRenew, true)
 }
 
 func (m *MetricsCollectorAzureRmReservation) Reset() {}
@@ -108,6 +250,86 @@ func (m *MetricsCollectorAzureRmReservation) Collect(callback chan<- func()) {
 	for _, scope := range Config.Collectors.Reservation.Scopes {
 		m.collectReservationUsage(m.Logger(), scope, callback)
 	}
+
+	if Config.Collectors.Reservation.Inventory {
+		m.collectReservationInventory(m.Logger())
+	}
+}
+
+// collectReservationInventory collects all reservations the exporter has access to
+func (m *MetricsCollectorAzureRmReservation) collectReservationInventory(logger *slog.Logger) {
+	infoMetric := m.Collector.GetMetricList("inventoryInfo")
+	expiryMetric := m.Collector.GetMetricList("inventoryExpiry")
+	effectiveMetric := m.Collector.GetMetricList("inventoryEffective")
+	quantityMetric := m.Collector.GetMetricList("inventoryQuantity")
+	renewMetric := m.Collector.GetMetricList("inventoryRenew")
+
+	client, err := armreservations.NewReservationClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	pager := client.NewListAllPager(nil)
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			panic(err)
+		}
+
+		for _, reservation := range result.Value {
+			properties := reservation.Properties
+			if properties == nil || (properties.Archived != nil && *properties.Archived) {
+				continue
+			}
+
+			// /providers/microsoft.capacity/reservationOrders/{reservationOrderId}/reservations/{reservationId}
+			reservationOrderID, reservationID := "", ""
+			if parts := strings.Split(strings.Trim(to.String(reservation.ID), "/"), "/"); len(parts) >= 6 {
+				reservationOrderID = parts[3]
+				reservationID = parts[5]
+			}
+
+			skuName := ""
+			if reservation.SKU != nil {
+				skuName = to.String(reservation.SKU.Name)
+			}
+
+			labels := prometheus.Labels{
+				"reservationOrderID": reservationOrderID,
+				"reservationID":      reservationID,
+			}
+
+			infoMetric.AddInfo(prometheus.Labels{
+				"reservationOrderID":   reservationOrderID,
+				"reservationID":        reservationID,
+				"displayName":          to.String(properties.DisplayName),
+				"skuName":              skuName,
+				"location":             to.StringLower(reservation.Location),
+				"reservedResourceType": to.StringLower((*string)(properties.ReservedResourceType)),
+				"term":                 to.StringLower((*string)(properties.Term)),
+				"appliedScopeType":     to.StringLower((*string)(properties.AppliedScopeType)),
+				"provisioningState":    to.StringLower((*string)(properties.ProvisioningState)),
+			})
+
+			if properties.ExpiryDateTime != nil {
+				expiryMetric.AddTime(labels, *properties.ExpiryDateTime)
+			}
+
+			if properties.EffectiveDateTime != nil {
+				effectiveMetric.AddTime(labels, *properties.EffectiveDateTime)
+			}
+
+			if properties.Quantity != nil {
+				quantityMetric.Add(labels, float64(*properties.Quantity))
+			}
+
+			if properties.Renew != nil {
+				renewMetric.AddBool(labels, *properties.Renew)
+			}
+		}
+	}
+
+	logger.Debug(`collected reservation inventory`)
 }
 
 func (m *MetricsCollectorAzureRmReservation) collectReservationUsage(logger *slog.Logger, scope string, callback chan<- func()) {
@@ -119,20 +341,20 @@ func (m *MetricsCollectorAzureRmReservation) collectReservationUsage(logger *slo
 	reservationReservedHours := m.Collector.GetMetricList("reservationReservedHours")
 	reservationTotalReservedQuantity := m.Collector.GetMetricList("reservationTotalReservedQuantity")
 
-	days := Config.Collectors.Reservation.FromDays
+	days := Config.Collectors.Reservation.GetFetchDays()
 	granularity := Config.Collectors.Reservation.Granularity
 
 	now := time.Now()
 	startDate := now.AddDate(0, 0, -days).Format("2006-01-02")
 	endDate := now.Format("2006-01-02")
 
-	clientFactory, err := armconsumption.NewClientFactory("<subscription-id>", AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	client, err := armconsumption.NewReservationsSummariesClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
 	if err != nil {
 		panic(err)
 	}
 
 	// Create a pager to retrieve daily booking summaries
-	pager := clientFactory.NewReservationsSummariesClient().NewListPager(scope, armconsumption.Datagrain(granularity), &armconsumption.ReservationsSummariesClientListOptions{
+	pager := client.NewListPager(scope, armconsumption.Datagrain(granularity), &armconsumption.ReservationsSummariesClientListOptions{
 		StartDate:          to.Ptr(startDate),
 		EndDate:            to.Ptr(endDate),
 		Filter:             nil,
@@ -140,30 +362,130 @@ func (m *MetricsCollectorAzureRmReservation) collectReservationUsage(logger *slo
 		ReservationOrderID: nil,
 	})
 
-	// Collect and export metrics
+	summaries := []*armconsumption.ReservationSummary{}
 	for pager.More() {
 		page, err := pager.NextPage(m.Context())
 		if err != nil {
 			panic(err)
 		}
 
-		for _, reservationProperties := range page.Value {
-			labels := prometheus.Labels{
-				"scope":              scope,
-				"reservationOrderID": to.String(reservationProperties.Properties.ReservationOrderID),
-				"reservationID":      to.String(reservationProperties.Properties.ReservationID),
-				"skuName":            to.String(reservationProperties.Properties.SKUName),
-				"kind":               to.String(reservationProperties.Properties.Kind),
-				"usageDate":          reservationProperties.Properties.UsageDate.String(),
+		for _, reservationSummary := range page.Value {
+			if reservationSummary.Properties == nil || reservationSummary.Properties.UsageDate == nil {
+				continue
 			}
+			summaries = append(summaries, reservationSummary)
+		}
+	}
+
+	completeGrainStart := reservationCompleteGrainStart(now, granularity)
+
+	m.collectReservationUtilizationAverages(scope, summaries, completeGrainStart)
 
-			reservationInfo.AddInfo(labels)
-			reservationUsage.AddIfNotNil(labels, reservationProperties.Properties.AvgUtilizationPercentage)
-			reservationMinUsage.AddIfNotNil(labels, reservationProperties.Properties.MinUtilizationPercentage)
-			reservationMaxUsage.AddIfNotNil(labels, reservationProperties.Properties.MaxUtilizationPercentage)
-			reservationUsedHours.AddIfNotNil(labels, reservationProperties.Properties.UsedHours)
-			reservationReservedHours.AddIfNotNil(labels, reservationProperties.Properties.ReservedHours)
-			reservationTotalReservedQuantity.AddIfNotNil(labels, reservationProperties.Properties.TotalReservedQuantity)
+	if Config.Collectors.Reservation.IsLatestMode() {
+		summaries = filterLatestReservationSummaries(summaries, completeGrainStart)
+	} else {
+		// fetched timerange might be larger because of averages
+		summaries = filterReservationSummariesFromDate(summaries, now.AddDate(0, 0, -Config.Collectors.Reservation.FromDays))
+	}
+
+	// Collect and export metrics
+	for _, reservationProperties := range summaries {
+		labels := prometheus.Labels{
+			"scope":              scope,
+			"reservationOrderID": to.String(reservationProperties.Properties.ReservationOrderID),
+			"reservationID":      to.String(reservationProperties.Properties.ReservationID),
+			"skuName":            to.String(reservationProperties.Properties.SKUName),
+			"kind":               to.String(reservationProperties.Properties.Kind),
+		}
+
+		if !Config.Collectors.Reservation.IsLatestMode() {
+			labels["usageDate"] = reservationProperties.Properties.UsageDate.String()
+		}
+
+		reservationInfo.AddInfo(labels)
+		reservationUsage.AddIfNotNil(labels, reservationProperties.Properties.AvgUtilizationPercentage)
+		reservationMinUsage.AddIfNotNil(labels, reservationProperties.Properties.MinUtilizationPercentage)
+		reservationMaxUsage.AddIfNotNil(labels, reservationProperties.Properties.MaxUtilizationPercentage)
+		reservationUsedHours.AddIfNotNil(labels, reservationProperties.Properties.UsedHours)
+		reservationReservedHours.AddIfNotNil(labels, reservationProperties.Properties.ReservedHours)
+		reservationTotalReservedQuantity.AddIfNotNil(labels, reservationProperties.Properties.TotalReservedQuantity)
+	}
+
+	if Config.Collectors.Reservation.Details {
+		m.collectReservationDetails(logger, scope, now)
+	}
+}
+
+// collectReservationUtilizationAverages exports the average utilization of complete grains per configured window
+func (m *MetricsCollectorAzureRmReservation) collectReservationUtilizationAverages(scope string, summaries []*armconsumption.ReservationSummary, completeGrainStart time.Time) {
+	reservationAverageUsage := m.Collector.GetMetricList("reservationAverageUsage")
+
+	for _, averageDays := range Config.Collectors.Reservation.AverageDays {
+		windowStart := completeGrainStart.AddDate(0, 0, -averageDays)
+
+		sum := map[string]float64{}
+		count := map[string]int{}
+		labels := map[string]prometheus.Labels{}
+		for _, summary := range summaries {
+			properties := summary.Properties
+			if properties.AvgUtilizationPercentage == nil || properties.UsageDate.Before(windowStart) || !properties.UsageDate.Before(completeGrainStart) {
+				continue
+			}
+
+			key := to.String(properties.ReservationOrderID) + "/" + to.String(properties.ReservationID)
+			if _, exists := labels[key]; !exists {
+				labels[key] = prometheus.Labels{
+					"scope":              scope,
+					"reservationOrderID": to.String(properties.ReservationOrderID),
+					"reservationID":      to.String(properties.ReservationID),
+					"skuName":            to.String(properties.SKUName),
+					"kind":               to.String(properties.Kind),
+					"window":             fmt.Sprintf("%dd", averageDays),
+				}
+			}
+			sum[key] += *properties.AvgUtilizationPercentage
+			count[key]++
+		}
+
+		for key, rowLabels := range labels {
+			reservationAverageUsage.Add(rowLabels, sum[key]/float64(count[key]))
+		}
+	}
+}
+
+// reservationCompleteGrainStart returns the start of the current (incomplete) grain,
+// all usage dates before are complete
+func reservationCompleteGrainStart(now time.Time, granularity string) time.Time {
+	now = now.UTC()
+	if strings.EqualFold(granularity, string(armconsumption.DatagrainMonthlyGrain)) {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// filterReservationSummariesFromDate returns the summaries of the day of fromDate and later,
+// usage dates are midnight so fromDate is truncated to the day (same as the startDate of the query)
+func filterReservationSummariesFromDate(summaries []*armconsumption.ReservationSummary, fromDate time.Time) []*armconsumption.ReservationSummary {
+	fromDay, _ := time.Parse("2006-01-02", fromDate.Format("2006-01-02"))
+	return slices.DeleteFunc(summaries, func(summary *armconsumption.ReservationSummary) bool {
+		return summary.Properties.UsageDate.Before(fromDay)
+	})
+}
+
+// filterLatestReservationSummaries returns the latest complete summary per reservation
+func filterLatestReservationSummaries(summaries []*armconsumption.ReservationSummary, completeGrainStart time.Time) []*armconsumption.ReservationSummary {
+	latest := map[string]*armconsumption.ReservationSummary{}
+	for _, summary := range summaries {
+		properties := summary.Properties
+		if !properties.UsageDate.Before(completeGrainStart) {
+			continue
+		}
+
+		key := to.String(properties.ReservationOrderID) + "/" + to.String(properties.ReservationID)
+		if existing, exists := latest[key]; !exists || properties.UsageDate.After(*existing.Properties.UsageDate) {
+			latest[key] = summary
 		}
 	}
+
+	return slices.Collect(maps.Values(latest))
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/consumption/armconsumption"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+// collectReservationDetails collects the reservation utilization per resource (instance) which consumed the reservation,
+// in latest mode only the last complete day with data is collected (per reservation)
+func (m *MetricsCollectorAzureRmReservation) collectReservationDetails(logger *slog.Logger, scope string, now time.Time) {
+	detailsUsedHours := m.Collector.GetMetricList("detailsUsedHours")
+	detailsReservedHours := m.Collector.GetMetricList("detailsReservedHours")
+
+	latestMode := Config.Collectors.Reservation.IsLatestMode()
+
+	endDate := now
+	startDate := now.AddDate(0, 0, -Config.Collectors.Reservation.FromDays)
+	if latestMode {
+		endDate = reservationCompleteGrainStart(now, string(armconsumption.DatagrainDailyGrain)).AddDate(0, 0, -1)
+		startDate = endDate.AddDate(0, 0, -(ReservationDetailsLatestLookbackDays - 1))
+	}
+
+	client, err := armconsumption.NewReservationsDetailsClient(AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	// billing profile scope uses start and end date, billing account scope uses filter
+	options := armconsumption.ReservationsDetailsClientListOptions{}
+	if strings.Contains(strings.ToLower(scope), "/billingprofiles/") {
+		options.StartDate = to.Ptr(startDate.Format("2006-01-02"))
+		options.EndDate = to.Ptr(endDate.Format("2006-01-02"))
+	} else {
+		options.Filter = to.Ptr(fmt.Sprintf(
+			`properties/UsageDate ge '%v' and properties/UsageDate le '%v'`,
+			startDate.Format("2006-01-02"),
+			endDate.Format("2006-01-02"),
+		))
+	}
+
+	reservationDetails := []*armconsumption.ReservationDetail{}
+	pager := client.NewListPager(scope, &options)
+	for pager.More() {
+		page, err := pager.NextPage(m.Context())
+		if err != nil {
+			panic(err)
+		}
+
+		for _, reservationDetail := range page.Value {
+			if reservationDetail.Properties == nil {
+				continue
+			}
+			reservationDetails = append(reservationDetails, reservationDetail)
+		}
+	}
+
+	if latestMode {
+		reservationDetails = filterLatestReservationDetails(reservationDetails)
+	}
+
+	for _, reservationDetail := range reservationDetails {
+		properties := reservationDetail.Properties
+
+		labels := prometheus.Labels{
+			"scope":                    scope,
+			"reservationOrderID":       to.String(properties.ReservationOrderID),
+			"reservationID":            to.String(properties.ReservationID),
+			"skuName":                  to.String(properties.SKUName),
+			"kind":                     to.String(properties.Kind),
+			"instanceID":               to.StringLower(properties.InstanceID),
+			"instanceFlexibilityGroup": to.String(properties.InstanceFlexibilityGroup),
+			"instanceFlexibilityRatio": to.String(properties.InstanceFlexibilityRatio),
+		}
+
+		if !latestMode {
+			usageDate := ""
+			if properties.UsageDate != nil {
+				usageDate = properties.UsageDate.String()
+			}
+			labels["usageDate"] = usageDate
+		}
+
+		detailsUsedHours.AddIfNotNil(labels, properties.UsedHours)
+		detailsReservedHours.AddIfNotNil(labels, properties.ReservedHours)
+	}
+
+	logger.Debug(`collected reservation details`)
+}
+
+// filterLatestReservationDetails returns the details of the last usage date with data per reservation
+func filterLatestReservationDetails(reservationDetails []*armconsumption.ReservationDetail) []*armconsumption.ReservationDetail {
+	latestUsageDate := map[string]time.Time{}
+	for _, reservationDetail := range reservationDetails {
+		properties := reservationDetail.Properties
+		if properties.UsageDate == nil {
+			continue
+		}
+
+		key := to.String(properties.ReservationOrderID) + "/" + to.String(properties.ReservationID)
+		if usageDate, exists := latestUsageDate[key]; !exists || properties.UsageDate.After(usageDate) {
+			latestUsageDate[key] = *properties.UsageDate
+		}
+	}
+
+	return slices.DeleteFunc(reservationDetails, func(reservationDetail *armconsumption.ReservationDetail) bool {
+		properties := reservationDetail.Properties
+		key := to.String(properties.ReservationOrderID) + "/" + to.String(properties.ReservationID)
+		return properties.UsageDate == nil || !properties.UsageDate.Equal(latestUsageDate[key])
+	})
+}
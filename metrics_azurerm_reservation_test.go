@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/consumption/armconsumption"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+func newReservationSummary(reservationID string, usageDate time.Time) *armconsumption.ReservationSummary {
+	return &armconsumption.ReservationSummary{
+		Properties: &armconsumption.ReservationSummaryProperties{
+			ReservationOrderID: to.Ptr("order"),
+			ReservationID:      to.Ptr(reservationID),
+			UsageDate:          &usageDate,
+		},
+	}
+}
+
+func TestFilterReservationSummariesFromDate(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		fromDate time.Time
+		expected []time.Time
+	}{
+		{
+			name:     "midnight",
+			fromDate: day(10),
+			expected: []time.Time{day(10), day(11)},
+		},
+		{
+			name:     "time of day keeps boundary day",
+			fromDate: day(10).Add(15*time.Hour + 30*time.Minute),
+			expected: []time.Time{day(10), day(11)},
+		},
+		{
+			name:     "end of day keeps boundary day",
+			fromDate: day(10).Add(23*time.Hour + 59*time.Minute),
+			expected: []time.Time{day(10), day(11)},
+		},
+		{
+			name:     "after last day",
+			fromDate: day(12).Add(time.Hour),
+			expected: []time.Time{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			summaries := []*armconsumption.ReservationSummary{
+				newReservationSummary("a", day(9)),
+				newReservationSummary("a", day(10)),
+				newReservationSummary("a", day(11)),
+			}
+
+			result := filterReservationSummariesFromDate(summaries, test.fromDate)
+			if len(result) != len(test.expected) {
+				t.Fatalf("expected %d summaries, got %d", len(test.expected), len(result))
+			}
+			for i, summary := range result {
+				if !summary.Properties.UsageDate.Equal(test.expected[i]) {
+					t.Errorf("expected usage date %v, got %v", test.expected[i], *summary.Properties.UsageDate)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/webdevops/go-common/prometheus/collector"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+const (
+	SavingsPlanInventoryApiVersion   = "2022-11-01"
+	SavingsPlanUtilizationApiVersion = "2023-11-01"
+)
+
+type (
+	MetricsCollectorAzureRmSavingsPlan struct {
+		collector.Processor
+
+		prometheus struct {
+			savingsPlanInfo          *prometheus.GaugeVec
+			savingsPlanUsage         *prometheus.GaugeVec
+			savingsPlanMinUsage      *prometheus.GaugeVec
+			savingsPlanMaxUsage      *prometheus.GaugeVec
+			savingsPlanBenefitDaily  *prometheus.GaugeVec
+			inventoryInfo            *prometheus.GaugeVec
+			inventoryExpiry          *prometheus.GaugeVec
+			inventoryEffective       *prometheus.GaugeVec
+			inventoryCommitment      *prometheus.GaugeVec
+			inventoryCommitmentDaily *prometheus.GaugeVec
+			inventoryRenew           *prometheus.GaugeVec
+		}
+	}
+
+	// savingsPlanInventoryList is the response of the savings plan api (Microsoft.BillingBenefits)
+	savingsPlanInventoryList struct {
+		Value    []savingsPlanInventoryItem `json:"value"`
+		NextLink *string                    `json:"nextLink"`
+	}
+
+	// savingsPlanCommitmentDaily is the commitment amount per day of a savings plan
+	savingsPlanCommitmentDaily struct {
+		Amount   float64
+		Currency string
+	}
+
+	savingsPlanInventoryItem struct {
+		ID   *string `json:"id"`
+		Name *string `json:"name"`
+		SKU  *struct {
+			Name *string `json:"name"`
+		} `json:"sku"`
+		Properties *struct {
+			DisplayName       *string `json:"displayName"`
+			ProvisioningState *string `json:"provisioningState"`
+			BillingScopeID    *string `json:"billingScopeId"`
+			BillingAccountID  *string `json:"billingAccountId"`
+			BillingProfileID  *string `json:"billingProfileId"`
+			Term              *string `json:"term"`
+			Renew             *bool   `json:"renew"`
+			AppliedScopeType  *string `json:"appliedScopeType"`
+			Commitment        *struct {
+				Grain        *string  `json:"grain"`
+				CurrencyCode *string  `json:"currencyCode"`
+				Amount       *float64 `json:"amount"`
+			} `json:"commitment"`
+			EffectiveDateTime *time.Time `json:"effectiveDateTime"`
+			ExpiryDateTime    *time.Time `json:"expiryDateTime"`
+		} `json:"properties"`
+	}
+
+	// savingsPlanUtilizationList is the response of the benefit utilization summaries api
+	savingsPlanUtilizationList struct {
+		Value    []savingsPlanUtilizationItem `json:"value"`
+		NextLink *string                      `json:"nextLink"`
+	}
+
+	savingsPlanUtilizationItem struct {
+		Kind       *string `json:"kind"`
+		Properties *struct {
+			ArmSkuName               *string    `json:"armSkuName"`
+			BenefitID                *string    `json:"benefitId"`
+			BenefitOrderID           *string    `json:"benefitOrderId"`
+			UsageDate                *time.Time `json:"usageDate"`
+			AvgUtilizationPercentage *float64   `json:"avgUtilizationPercentage"`
+			MinUtilizationPercentage *float64   `json:"minUtilizationPercentage"`
+			MaxUtilizationPercentage *float64   `json:"maxUtilizationPercentage"`
+		} `json:"properties"`
+	}
+)
+
+func (m *MetricsCollectorAzureRmSavingsPlan) Setup(collector *collector.Collector) {
+	m.Processor.Setup(collector)
+
+	// labels are consistent with reservation metrics
+	commonLabels := []string{
+		"scope",
+		"savingsPlanOrderID",
+		"savingsPlanID",
+		"skuName",
+		"kind",
+		"usageDate",
+	}
+
+	m.prometheus.savingsPlanInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_info",
+			Help: "Azure ResourceManager SavingsPlan Information",
+		},
+		commonLabels,
+	)
+	m.Collector.RegisterMetricList("savingsPlanInfo", m.prometheus.savingsPlanInfo, true)
+
+	m.prometheus.savingsPlanUsage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_utilization",
+			Help: "Azure ResourceManager SavingsPlan Utilization",
+		},
+		commonLabels,
+	)
+	m.Collector.RegisterMetricList("savingsPlanUsage", m.prometheus.savingsPlanUsage, true)
+
+	m.prometheus.savingsPlanMinUsage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_utilization_min",
+			Help: "Azure ResourceManager SavingsPlan Min Utilization",
+		},
+		commonLabels,
+	)
+	m.Collector.RegisterMetricList("savingsPlanMinUsage", m.prometheus.savingsPlanMinUsage, true)
+
+	m.prometheus.savingsPlanMaxUsage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_utilization_max",
+			Help: "Azure ResourceManager SavingsPlan Max Utilization",
+		},
+		commonLabels,
+	)
+	m.Collector.RegisterMetricList("savingsPlanMaxUsage", m.prometheus.savingsPlanMaxUsage, true)
+
+	// utilization summaries only contain percentages, used benefit is derived from the commitment
+	m.prometheus.savingsPlanBenefitDaily = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_benefit_per_day",
+			Help: "Azure ResourceManager SavingsPlan used benefit per day (commitment per day multiplied with average utilization)",
+		},
+		append(slices.Clone(commonLabels), "currency"),
+	)
+	m.Collector.RegisterMetricList("savingsPlanBenefitDaily", m.prometheus.savingsPlanBenefitDaily, true)
+
+	// ----------------------------------------------------
+	// Inventory
+
+	inventoryLabels := []string{
+		"scope",
+		"savingsPlanOrderID",
+		"savingsPlanID",
+	}
+
+	m.prometheus.inventoryInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_inventory_info",
+			Help: "Azure ResourceManager SavingsPlan inventory information",
+		},
+		[]string{
+			"scope",
+			"savingsPlanOrderID",
+			"savingsPlanID",
+			"displayName",
+			"skuName",
+			"term",
+			"appliedScopeType",
+			"billingScopeID",
+			"provisioningState",
+		},
+	)
+	m.Collector.RegisterMetricList("inventoryInfo", m.prometheus.inventoryInfo, true)
+
+	m.prometheus.inventoryExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_inventory_expiry_timestamp_seconds",
+			Help: "Azure ResourceManager SavingsPlan expiry timestamp",
+		},
+		inventoryLabels,
+	)
+	m.Collector.RegisterMetricList("inventoryExpiry", m.prometheus.inventoryExpiry, true)
+
+	m.prometheus.inventoryEffective = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_inventory_effective_timestamp_seconds",
+			Help: "Azure ResourceManager SavingsPlan effective timestamp",
+		},
+		inventoryLabels,
+	)
+	m.Collector.RegisterMetricList("inventoryEffective", m.prometheus.inventoryEffective, true)
+
+	m.prometheus.inventoryCommitment = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_inventory_commitment_amount",
+			Help: "Azure ResourceManager SavingsPlan commitment amount (per commitment granularity)",
+		},
+		[]string{
+			"scope",
+			"savingsPlanOrderID",
+			"savingsPlanID",
+			"grain",
+			"currency",
+		},
+	)
+	m.Collector.RegisterMetricList("inventoryCommitment", m.prometheus.inventoryCommitment, true)
+
+	m.prometheus.inventoryCommitmentDaily = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_inventory_commitment_per_day",
+			Help: "Azure ResourceManager SavingsPlan commitment amount per day",
+		},
+		[]string{
+			"scope",
+			"savingsPlanOrderID",
+			"savingsPlanID",
+			"currency",
+		},
+	)
+	m.Collector.RegisterMetricList("inventoryCommitmentDaily", m.prometheus.inventoryCommitmentDaily, true)
+
+	m.prometheus.inventoryRenew = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_inventory_renew",
+			Help: "Azure ResourceManager SavingsPlan auto renew status",
+		},
+		inventoryLabels,
+	)
+	m.Collector.RegisterMetricList("inventoryRenew", m.prometheus.inventoryRenew, true)
+}
+
+func (m *MetricsCollectorAzureRmSavingsPlan) Reset() {}
+
+func (m *MetricsCollectorAzureRmSavingsPlan) Collect(callback chan<- func()) {
+	if len(Config.Collectors.SavingsPlan.Scopes) == 0 {
+		m.Logger().Warn(`no scopes configured, savings plans are only available on billing account or billing profile scope`)
+		return
+	}
+
+	savingsPlans := m.fetchSavingsPlanInventory()
+
+	for _, scope := range Config.Collectors.SavingsPlan.Scopes {
+		scopeLogger := m.Logger().With(slog.String("scope", scope))
+		commitments := m.collectSavingsPlanInventory(scopeLogger, scope, savingsPlans)
+		m.collectSavingsPlanUtilization(scopeLogger, scope, commitments)
+	}
+}
+
+// fetchSavingsPlanInventory fetches all savings plans the exporter has access to
+func (m *MetricsCollectorAzureRmSavingsPlan) fetchSavingsPlanInventory() []savingsPlanInventoryItem {
+	savingsPlans := []savingsPlanInventoryItem{}
+
+	requestUrl := armRestUrl(
+		"/providers/Microsoft.BillingBenefits/savingsPlans",
+		url.Values{
+			"api-version": {SavingsPlanInventoryApiVersion},
+		},
+	)
+
+	for requestUrl != "" {
+		result := savingsPlanInventoryList{}
+		if _, err := armRestGet(m.Context(), requestUrl, &result); err != nil {
+			panic(err)
+		}
+
+		savingsPlans = append(savingsPlans, result.Value...)
+		requestUrl = to.String(result.NextLink)
+	}
+
+	return savingsPlans
+}
+
+// collectSavingsPlanInventory collects the savings plans belonging to the billing account or billing profile scope,
+// returns the commitment per day of the savings plans (key is the lowercase savings plan id)
+func (m *MetricsCollectorAzureRmSavingsPlan) collectSavingsPlanInventory(logger *slog.Logger, scope string, savingsPlans []savingsPlanInventoryItem) map[string]savingsPlanCommitmentDaily {
+	commitments := map[string]savingsPlanCommitmentDaily{}
+
+	infoMetric := m.Collector.GetMetricList("inventoryInfo")
+	expiryMetric := m.Collector.GetMetricList("inventoryExpiry")
+	effectiveMetric := m.Collector.GetMetricList("inventoryEffective")
+	commitmentMetric := m.Collector.GetMetricList("inventoryCommitment")
+	commitmentDailyMetric := m.Collector.GetMetricList("inventoryCommitmentDaily")
+	renewMetric := m.Collector.GetMetricList("inventoryRenew")
+
+	matchCount := 0
+	for _, savingsPlan := range savingsPlans {
+		properties := savingsPlan.Properties
+		if properties == nil {
+			continue
+		}
+
+		if !savingsPlanMatchesScope(scope, to.String(properties.BillingAccountID), to.String(properties.BillingProfileID)) {
+			continue
+		}
+		matchCount++
+
+		// /providers/Microsoft.BillingBenefits/savingsPlanOrders/{savingsPlanOrderId}/savingsPlans/{savingsPlanId}
+		savingsPlanOrderID, savingsPlanID := parseSavingsPlanResourceId(to.String(savingsPlan.ID))
+
+		skuName := ""
+		if savingsPlan.SKU != nil {
+			skuName = to.String(savingsPlan.SKU.Name)
+		}
+
+		labels := prometheus.Labels{
+			"scope":              scope,
+			"savingsPlanOrderID": savingsPlanOrderID,
+			"savingsPlanID":      savingsPlanID,
+		}
+
+		infoMetric.AddInfo(prometheus.Labels{
+			"scope":              scope,
+			"savingsPlanOrderID": savingsPlanOrderID,
+			"savingsPlanID":      savingsPlanID,
+			"displayName":        to.String(properties.DisplayName),
+			"skuName":            skuName,
+			"term":               to.StringLower(properties.Term),
+			"appliedScopeType":   to.StringLower(properties.AppliedScopeType),
+			"billingScopeID":     to.StringLower(properties.BillingScopeID),
+			"provisioningState":  to.StringLower(properties.ProvisioningState),
+		})
+
+		if properties.ExpiryDateTime != nil {
+			expiryMetric.AddTime(labels, *properties.ExpiryDateTime)
+		}
+
+		if properties.EffectiveDateTime != nil {
+			effectiveMetric.AddTime(labels, *properties.EffectiveDateTime)
+		}
+
+		if properties.Renew != nil {
+			renewMetric.AddBool(labels, *properties.Renew)
+		}
+
+		if commitment := properties.Commitment; commitment != nil && commitment.Amount != nil {
+			commitmentMetric.Add(prometheus.Labels{
+				"scope":              scope,
+				"savingsPlanOrderID": savingsPlanOrderID,
+				"savingsPlanID":      savingsPlanID,
+				"grain":              to.StringLower(commitment.Grain),
+				"currency":           to.String(commitment.CurrencyCode),
+			}, *commitment.Amount)
+
+			var commitmentPerDay *float64
+			switch strings.ToLower(to.String(commitment.Grain)) {
+			case "hourly":
+				commitmentPerDay = to.Ptr(*commitment.Amount * 24)
+			case "daily":
+				commitmentPerDay = commitment.Amount
+			default:
+				logger.Debug(`unknown savings plan commitment grain`, slog.String("savingsPlanID", savingsPlanID), slog.String("grain", to.String(commitment.Grain)))
+			}
+
+			commitmentDailyMetric.AddIfNotNil(prometheus.Labels{
+				"scope":              scope,
+				"savingsPlanOrderID": savingsPlanOrderID,
+				"savingsPlanID":      savingsPlanID,
+				"currency":           to.String(commitment.CurrencyCode),
+			}, commitmentPerDay)
+
+			if commitmentPerDay != nil {
+				commitments[strings.ToLower(savingsPlanID)] = savingsPlanCommitmentDaily{
+					Amount:   *commitmentPerDay,
+					Currency: to.String(commitment.CurrencyCode),
+				}
+			}
+		}
+	}
+
+	if matchCount == 0 && len(savingsPlans) > 0 {
+		logger.Warn(`no savings plan matches scope, scope must be a billing account or billing profile id`, slog.Int("savingsPlans", len(savingsPlans)))
+	}
+
+	return commitments
+}
+
+// savingsPlanMatchesScope checks if the savings plan is billed to the billing account or billing profile scope
+// scope and savings plan ids are compared by their normalized billing account and billing profile ids
+func savingsPlanMatchesScope(scope, billingAccountID, billingProfileID string) bool {
+	scopeInfo := parseBillingScope(scope)
+	if scopeInfo.BillingAccountID == "" {
+		// plain billing account id
+		scopeInfo.BillingAccountID = strings.Trim(scope, "/")
+	}
+
+	accountInfo := parseBillingScope(billingAccountID)
+	profileInfo := parseBillingScope(billingProfileID)
+	if !strings.EqualFold(scopeInfo.BillingAccountID, accountInfo.BillingAccountID) && !strings.EqualFold(scopeInfo.BillingAccountID, profileInfo.BillingAccountID) {
+		return false
+	}
+
+	if scopeInfo.BillingProfileID == "" {
+		return true
+	}
+
+	return strings.EqualFold(scopeInfo.BillingProfileID, profileInfo.BillingProfileID)
+}
+
+// collectSavingsPlanUtilization collects the benefit utilization summaries of savings plans
+// the used benefit per day is derived from the commitment per day of the savings plan inventory
+func (m *MetricsCollectorAzureRmSavingsPlan) collectSavingsPlanUtilization(logger *slog.Logger, scope string, commitments map[string]savingsPlanCommitmentDaily) {
+	savingsPlanInfo := m.Collector.GetMetricList("savingsPlanInfo")
+	savingsPlanUsage := m.Collector.GetMetricList("savingsPlanUsage")
+	savingsPlanMinUsage := m.Collector.GetMetricList("savingsPlanMinUsage")
+	savingsPlanMaxUsage := m.Collector.GetMetricList("savingsPlanMaxUsage")
+	savingsPlanBenefitDaily := m.Collector.GetMetricList("savingsPlanBenefitDaily")
+
+	now := time.Now()
+	startDate := now.AddDate(0, 0, -Config.Collectors.SavingsPlan.GetFromDays()).Format("2006-01-02")
+	endDate := now.Format("2006-01-02")
+
+	granularity := Config.Collectors.SavingsPlan.GetGranularity()
+
+	requestUrl := armRestUrl(
+		scope+"/providers/Microsoft.CostManagement/benefitUtilizationSummaries",
+		url.Values{
+			"api-version":    {SavingsPlanUtilizationApiVersion},
+			"grainParameter": {strings.ToUpper(granularity[:1]) + strings.ToLower(granularity[1:])},
+			"$filter": {fmt.Sprintf(
+				`properties/usageDate ge '%v' and properties/usageDate le '%v' and properties/kind eq 'SavingsPlan'`,
+				startDate,
+				endDate,
+			)},
+		},
+	)
+
+	for requestUrl != "" {
+		result := savingsPlanUtilizationList{}
+		if _, err := armRestGet(m.Context(), requestUrl, &result); err != nil {
+			panic(err)
+		}
+
+		for _, summary := range result.Value {
+			properties := summary.Properties
+			if properties == nil {
+				continue
+			}
+
+			_, savingsPlanOrderID := parseSavingsPlanResourceId(to.String(properties.BenefitOrderID))
+			_, savingsPlanID := parseSavingsPlanResourceId(to.String(properties.BenefitID))
+
+			usageDate := ""
+			if properties.UsageDate != nil {
+				usageDate = properties.UsageDate.String()
+			}
+
+			labels := prometheus.Labels{
+				"scope":              scope,
+				"savingsPlanOrderID": savingsPlanOrderID,
+				"savingsPlanID":      savingsPlanID,
+				"skuName":            to.String(properties.ArmSkuName),
+				"kind":               to.String(summary.Kind),
+				"usageDate":          usageDate,
+			}
+
+			savingsPlanInfo.AddInfo(labels)
+			savingsPlanUsage.AddIfNotNil(labels, properties.AvgUtilizationPercentage)
+			savingsPlanMinUsage.AddIfNotNil(labels, properties.MinUtilizationPercentage)
+			savingsPlanMaxUsage.AddIfNotNil(labels, properties.MaxUtilizationPercentage)
+
+			if commitment, exists := commitments[strings.ToLower(savingsPlanID)]; exists && properties.AvgUtilizationPercentage != nil {
+				benefitLabels := maps.Clone(labels)
+				benefitLabels["currency"] = commitment.Currency
+				savingsPlanBenefitDaily.Add(benefitLabels, *properties.AvgUtilizationPercentage/100*commitment.Amount)
+			}
+		}
+
+		requestUrl = to.String(result.NextLink)
+	}
+
+	logger.Debug(`collected savings plan utilization`)
+}
+
+// parseSavingsPlanResourceId returns the order id and the id of the last resource
+// eg. /providers/Microsoft.BillingBenefits/savingsPlanOrders/{savingsPlanOrderId}/savingsPlans/{savingsPlanId}
+func parseSavingsPlanResourceId(resourceId string) (savingsPlanOrderID, lastID string) {
+	parts := strings.Split(strings.Trim(resourceId, "/"), "/")
+	if len(parts) >= 4 {
+		savingsPlanOrderID = parts[3]
+	}
+	if len(parts) >= 1 {
+		lastID = parts[len(parts)-1]
+	}
+	return
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcehealth/armresourcehealth"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/webdevops/go-common/prometheus/collector"
+	"github.com/webdevops/go-common/utils/to"
+)
+
+type MetricsCollectorAzureRmServiceHealth struct {
+	collector.Processor
+
+	prometheus struct {
+		serviceHealthEvent           *prometheus.GaugeVec
+		serviceHealthEventImpact     *prometheus.GaugeVec
+		serviceHealthEventStart      *prometheus.GaugeVec
+		serviceHealthEventLastUpdate *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmServiceHealth) Setup(collector *collector.Collector) {
+	m.Processor.Setup(collector)
+
+	m.prometheus.serviceHealthEvent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_servicehealth_event_info",
+			Help: "Azure Service Health active event (service issue, planned maintenance, health and security advisory)",
+		},
+		[]string{
+			"subscriptionID",
+			"trackingID",
+			"eventType",
+			"status",
+			"level",
+			"title",
+		},
+	)
+	m.Collector.RegisterMetricList("serviceHealthEvent", m.prometheus.serviceHealthEvent, true)
+
+	m.prometheus.serviceHealthEventImpact = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_servicehealth_event_impact",
+			Help: "Azure Service Health active event impacted service and region",
+		},
+		[]string{
+			"subscriptionID",
+			"trackingID",
+			"service",
+			"region",
+			"status",
+		},
+	)
+	m.Collector.RegisterMetricList("serviceHealthEventImpact", m.prometheus.serviceHealthEventImpact, true)
+
+	m.prometheus.serviceHealthEventStart = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_servicehealth_event_start_timestamp_seconds",
+			Help: "Azure Service Health active event impact start time",
+		},
+		[]string{
+			"subscriptionID",
+			"trackingID",
+		},
+	)
+	m.Collector.RegisterMetricList("serviceHealthEventStart", m.prometheus.serviceHealthEventStart, true)
+
+	m.prometheus.serviceHealthEventLastUpdate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_servicehealth_event_last_update_timestamp_seconds",
+			Help: "Azure Service Health active event last update time",
+		},
+		[]string{
+			"subscriptionID",
+			"trackingID",
+		},
+	)
+	m.Collector.RegisterMetricList("serviceHealthEventLastUpdate", m.prometheus.serviceHealthEventLastUpdate, true)
+}
+
+func (m *MetricsCollectorAzureRmServiceHealth) Reset() {}
+
+func (m *MetricsCollectorAzureRmServiceHealth) Collect(callback chan<- func()) {
+	err := AzureSubscriptionsIterator.ForEachAsync(m.Logger(), func(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
+		m.collectServiceHealthEvents(subscription, logger)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (m *MetricsCollectorAzureRmServiceHealth) collectServiceHealthEvents(subscription *armsubscriptions.Subscription, logger *slog.Logger) {
+	client, err := armresourcehealth.NewEventsClient(*subscription.SubscriptionID, AzureClient.GetCred(), AzureClient.NewArmClientOptions())
+	if err != nil {
+		panic(err)
+	}
+
+	eventMetrics := m.Collector.GetMetricList("serviceHealthEvent")
+	impactMetrics := m.Collector.GetMetricList("serviceHealthEventImpact")
+	startMetrics := m.Collector.GetMetricList("serviceHealthEventStart")
+	lastUpdateMetrics := m.Collector.GetMetricList("serviceHealthEventLastUpdate")
+
+	pager := client.NewListBySubscriptionIDPager(nil)
+	for pager.More() {
+		result, err := pager.NextPage(m.Context())
+		if err != nil {
+			panic(err)
+		}
+
+		for _, event := range result.Value {
+			if event.Properties == nil {
+				continue
+			}
+
+			// only active events (resolved events are kept by the api for 90 days)
+			if event.Properties.Status == nil || *event.Properties.Status != armresourcehealth.EventStatusValuesActive {
+				continue
+			}
+
+			eventType := ""
+			if event.Properties.EventType != nil {
+				eventType = string(*event.Properties.EventType)
+			}
+
+			if !Config.Collectors.ServiceHealth.IsEventTypeEnabled(eventType) {
+				continue
+			}
+
+			level := ""
+			if event.Properties.EventLevel != nil {
+				level = stringToStringLower(string(*event.Properties.EventLevel))
+			}
+
+			title := to.String(event.Properties.Title)
+			if Config.Collectors.ServiceHealth.TitleMaxLength > 0 {
+				title = truncateStrings(title, Config.Collectors.ServiceHealth.TitleMaxLength, "...")
+			}
+
+			trackingID := to.String(event.Name)
+
+			eventMetrics.AddInfo(prometheus.Labels{
+				"subscriptionID": to.StringLower(subscription.SubscriptionID),
+				"trackingID":     trackingID,
+				"eventType":      stringToStringLower(eventType),
+				"status":         stringToStringLower(string(*event.Properties.Status)),
+				"level":          level,
+				"title":          title,
+			})
+
+			for _, impact := range event.Properties.Impact {
+				for _, region := range impact.ImpactedRegions {
+					status := ""
+					if region.Status != nil {
+						status = stringToStringLower(string(*region.Status))
+					}
+
+					impactMetrics.AddInfo(prometheus.Labels{
+						"subscriptionID": to.StringLower(subscription.SubscriptionID),
+						"trackingID":     trackingID,
+						"service":        to.String(impact.ImpactedService),
+						"region":         to.String(region.ImpactedRegion),
+						"status":         status,
+					})
+				}
+			}
+
+			labels := prometheus.Labels{
+				"subscriptionID": to.StringLower(subscription.SubscriptionID),
+				"trackingID":     trackingID,
+			}
+
+			if event.Properties.ImpactStartTime != nil {
+				startMetrics.AddTime(labels, *event.Properties.ImpactStartTime)
+			}
+
+			if event.Properties.LastUpdateTime != nil {
+				lastUpdateMetrics.AddTime(labels, *event.Properties.LastUpdateTime)
+			}
+		}
+	}
+}
@@ -1,17 +1,73 @@
 package metrics
 
 import (
+	"context"
 	"log/slog"
+	"maps"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 )
 
-type CostRateLimitPolicy struct {
-	Logger *slog.Logger
-}
+type (
+	CostRateLimitPolicy struct {
+		Logger *slog.Logger
+		State  *CostRateLimitState
+	}
+
+	// CostRateLimitState keeps the rate limit headroom reported by the cost management api
+	// and paces the requests based on it (shared between all cost queries)
+	CostRateLimitState struct {
+		lock sync.Mutex
+
+		// wait time if headroom is exhausted
+		Backoff time.Duration
+		// requests are paced if remaining qpu or requests are at or below these values
+		MinQpuRemaining      float64
+		MinRequestsRemaining float64
+
+		qpuConsumed       *float64
+		qpuRemaining      map[string]float64
+		requestsRemaining map[string]float64
+
+		lastUpdate   time.Time
+		blockedUntil time.Time
+	}
+
+	CostRateLimitHeadroom struct {
+		QpuConsumed       *float64
+		QpuRemaining      map[string]float64
+		RequestsRemaining map[string]float64
+	}
+)
+
+var (
+	costRateLimitRequestHeaders = map[string]string{
+		"costmanagement-entity": "x-ms-ratelimit-remaining-microsoft.costmanagement-entity-requests",
+		"costmanagement-tenant": "x-ms-ratelimit-remaining-microsoft.costmanagement-tenant-requests",
+		"consumption-tenant":    "x-ms-ratelimit-remaining-microsoft.consumption-tenant-requests",
+	}
+
+	costRateLimitRetryAfterHeaders = []string{
+		"x-ms-ratelimit-microsoft.costmanagement-qpu-retry-after",
+		"x-ms-ratelimit-microsoft.costmanagement-entity-retry-after",
+		"x-ms-ratelimit-microsoft.costmanagement-tenant-retry-after",
+		"x-ms-ratelimit-microsoft.consumption-retry-after",
+		"retry-after",
+	}
+)
 
 func (p CostRateLimitPolicy) Do(req *policy.Request) (*http.Response, error) {
+	if p.State != nil {
+		if err := p.State.Wait(req.Raw().Context()); err != nil {
+			return nil, err
+		}
+	}
+
 	p.Logger.Debug("sending cost query")
 	// Forward the request to the next policy in the pipeline.
 	resp, err := req.Next()
@@ -22,6 +78,10 @@ func (p CostRateLimitPolicy) Do(req *policy.Request) (*http.Response, error) {
 		p.checkFoRateLimit("costmanagement-entity-requests", "x-ms-ratelimit-remaining-microsoft.costmanagement-entity-requests", resp)
 		p.checkFoRateLimit("costmanagement-tenant-requests", "x-ms-ratelimit-remaining-microsoft.costmanagement-tenant-requests", resp)
 		p.checkFoRateLimit("consumption-tenant-requests", "x-ms-ratelimit-remaining-microsoft.consumption-tenant-requests", resp)
+
+		if p.State != nil {
+			p.State.Update(resp)
+		}
 	}
 
 	return resp, err
@@ -32,3 +92,156 @@ func (p CostRateLimitPolicy) checkFoRateLimit(name, header string, resp *http.Re
 		p.Logger.Debug(`detected ratelimit`, slog.String("name", name), slog.String("value", val))
 	}
 }
+
+func NewCostRateLimitState(backoff time.Duration, minQpuRemaining, minRequestsRemaining float64) *CostRateLimitState {
+	return &CostRateLimitState{
+		Backoff:              backoff,
+		MinQpuRemaining:      minQpuRemaining,
+		MinRequestsRemaining: minRequestsRemaining,
+		qpuRemaining:         map[string]float64{},
+		requestsRemaining:    map[string]float64{},
+	}
+}
+
+// Wait blocks until the next request can be sent without exceeding the rate limit
+func (s *CostRateLimitState) Wait(ctx context.Context) error {
+	for {
+		delay := s.reserve()
+		if delay <= 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// reserve returns the time until the next request is allowed
+// or reserves the request slot if no wait is needed
+func (s *CostRateLimitState) reserve() time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+
+	if now.Before(s.blockedUntil) {
+		return s.blockedUntil.Sub(now)
+	}
+
+	if s.isExhausted() {
+		// headroom is only updated by responses, so only one request
+		// is sent per backoff to check if the rate limit has recovered
+		if nextRequest := s.lastUpdate.Add(s.Backoff); now.Before(nextRequest) {
+			return nextRequest.Sub(now)
+		}
+		s.lastUpdate = now
+	}
+
+	return 0
+}
+
+func (s *CostRateLimitState) isExhausted() bool {
+	for _, val := range s.qpuRemaining {
+		if val <= s.MinQpuRemaining {
+			return true
+		}
+	}
+
+	for _, val := range s.requestsRemaining {
+		if val <= s.MinRequestsRemaining {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Update parses the rate limit headers of the response
+func (s *CostRateLimitState) Update(resp *http.Response) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.lastUpdate = now
+
+	if val := resp.Header.Get("x-ms-ratelimit-microsoft.costmanagement-qpu-consumed"); val != "" {
+		if consumed, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+			s.qpuConsumed = &consumed
+		}
+	}
+
+	if val := resp.Header.Get("x-ms-ratelimit-microsoft.costmanagement-qpu-remaining"); val != "" {
+		s.qpuRemaining = parseCostRateLimitQuotaList(val)
+	}
+
+	for name, header := range costRateLimitRequestHeaders {
+		if val := resp.Header.Get(header); val != "" {
+			if remaining, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+				s.requestsRemaining[name] = remaining
+			}
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// block all requests until the rate limit window is over
+		retryAfter := s.Backoff
+		for _, header := range costRateLimitRetryAfterHeaders {
+			if val := resp.Header.Get(header); val != "" {
+				if seconds, err := strconv.Atoi(strings.TrimSpace(val)); err == nil && time.Duration(seconds)*time.Second > retryAfter {
+					retryAfter = time.Duration(seconds) * time.Second
+				}
+			}
+		}
+
+		if blockedUntil := now.Add(retryAfter); blockedUntil.After(s.blockedUntil) {
+			s.blockedUntil = blockedUntil
+		}
+	}
+}
+
+// Headroom returns the last reported rate limit headroom
+func (s *CostRateLimitState) Headroom() CostRateLimitHeadroom {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ret := CostRateLimitHeadroom{
+		QpuRemaining:      maps.Clone(s.qpuRemaining),
+		RequestsRemaining: maps.Clone(s.requestsRemaining),
+	}
+
+	if s.qpuConsumed != nil {
+		consumed := *s.qpuConsumed
+		ret.QpuConsumed = &consumed
+	}
+
+	return ret
+}
+
+// parseCostRateLimitQuotaList parses quota lists like "QueryResource:10s:12,QueryResource:1m:58"
+// into a map of quota window and remaining value, plain values are returned with an empty window
+func parseCostRateLimitQuotaList(val string) map[string]float64 {
+	ret := map[string]float64{}
+
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		window := ""
+		value := item
+		if pos := strings.LastIndexAny(item, ":="); pos >= 0 {
+			window = strings.TrimSpace(item[:pos])
+			value = strings.TrimSpace(item[pos+1:])
+		}
+
+		if remaining, err := strconv.ParseFloat(value, 64); err == nil {
+			ret[window] = remaining
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,204 @@
+package metrics
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseCostRateLimitQuotaList(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected map[string]float64
+	}{
+		{
+			name:     "empty",
+			value:    "",
+			expected: map[string]float64{},
+		},
+		{
+			name:     "plain value",
+			value:    "12",
+			expected: map[string]float64{"": 12},
+		},
+		{
+			name:  "quota windows",
+			value: "QueryResource:10s:12,QueryResource:1m:58",
+			expected: map[string]float64{
+				"QueryResource:10s": 12,
+				"QueryResource:1m":  58,
+			},
+		},
+		{
+			name:  "quota windows with equal sign and spaces",
+			value: " QueryResource:10s=12 , QueryResource:1h=100 ",
+			expected: map[string]float64{
+				"QueryResource:10s": 12,
+				"QueryResource:1h":  100,
+			},
+		},
+		{
+			name:  "invalid values are ignored",
+			value: "QueryResource:10s:abc,,QueryResource:1m:5.5",
+			expected: map[string]float64{
+				"QueryResource:1m": 5.5,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := parseCostRateLimitQuotaList(test.value)
+			if !maps.Equal(result, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func newCostRateLimitResponse(statusCode int, headers map[string]string) *http.Response {
+	resp := &http.Response{
+		StatusCode: statusCode,
+		Header:     http.Header{},
+	}
+	for name, value := range headers {
+		resp.Header.Set(name, value)
+	}
+	return resp
+}
+
+func TestCostRateLimitStateUpdate(t *testing.T) {
+	tests := []struct {
+		name              string
+		resp              *http.Response
+		qpuConsumed       *float64
+		qpuRemaining      map[string]float64
+		requestsRemaining map[string]float64
+		blocked           bool
+	}{
+		{
+			name:              "no headers",
+			resp:              newCostRateLimitResponse(http.StatusOK, nil),
+			qpuRemaining:      map[string]float64{},
+			requestsRemaining: map[string]float64{},
+		},
+		{
+			name: "headroom headers",
+			resp: newCostRateLimitResponse(http.StatusOK, map[string]string{
+				"x-ms-ratelimit-microsoft.costmanagement-qpu-consumed":              "3",
+				"x-ms-ratelimit-microsoft.costmanagement-qpu-remaining":             "QueryResource:10s:12,QueryResource:1m:58",
+				"x-ms-ratelimit-remaining-microsoft.costmanagement-entity-requests": "9",
+				"x-ms-ratelimit-remaining-microsoft.consumption-tenant-requests":    "invalid",
+			}),
+			qpuConsumed: func() *float64 { val := float64(3); return &val }(),
+			qpuRemaining: map[string]float64{
+				"QueryResource:10s": 12,
+				"QueryResource:1m":  58,
+			},
+			requestsRemaining: map[string]float64{
+				"costmanagement-entity": 9,
+			},
+		},
+		{
+			name: "too many requests",
+			resp: newCostRateLimitResponse(http.StatusTooManyRequests, map[string]string{
+				"x-ms-ratelimit-microsoft.costmanagement-qpu-retry-after": "60",
+			}),
+			qpuRemaining:      map[string]float64{},
+			requestsRemaining: map[string]float64{},
+			blocked:           true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := NewCostRateLimitState(time.Second, 0, 0)
+			state.Update(test.resp)
+
+			headroom := state.Headroom()
+			switch {
+			case test.qpuConsumed == nil && headroom.QpuConsumed != nil:
+				t.Errorf("expected no consumed qpu, got %v", *headroom.QpuConsumed)
+			case test.qpuConsumed != nil && (headroom.QpuConsumed == nil || *headroom.QpuConsumed != *test.qpuConsumed):
+				t.Errorf("expected consumed qpu %v, got %v", *test.qpuConsumed, headroom.QpuConsumed)
+			}
+
+			if !maps.Equal(headroom.QpuRemaining, test.qpuRemaining) {
+				t.Errorf("expected remaining qpu %v, got %v", test.qpuRemaining, headroom.QpuRemaining)
+			}
+
+			if !maps.Equal(headroom.RequestsRemaining, test.requestsRemaining) {
+				t.Errorf("expected remaining requests %v, got %v", test.requestsRemaining, headroom.RequestsRemaining)
+			}
+
+			if blocked := state.reserve() > 0; blocked != test.blocked {
+				t.Errorf("expected blocked %v, got %v", test.blocked, blocked)
+			}
+
+			if test.blocked && state.reserve() <= 30*time.Second {
+				t.Errorf("expected retry-after header (60s) to be used instead of backoff")
+			}
+		})
+	}
+}
+
+func TestCostRateLimitStateWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		// request after update needs to wait
+		wait bool
+	}{
+		{
+			name: "headroom available",
+			headers: map[string]string{
+				"x-ms-ratelimit-microsoft.costmanagement-qpu-remaining":             "QueryResource:10s:12",
+				"x-ms-ratelimit-remaining-microsoft.costmanagement-entity-requests": "9",
+			},
+			wait: false,
+		},
+		{
+			name: "qpu exhausted",
+			headers: map[string]string{
+				"x-ms-ratelimit-microsoft.costmanagement-qpu-remaining": "QueryResource:10s:0",
+			},
+			wait: true,
+		},
+		{
+			name: "requests exhausted",
+			headers: map[string]string{
+				"x-ms-ratelimit-remaining-microsoft.costmanagement-tenant-requests": "1",
+			},
+			wait: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := NewCostRateLimitState(time.Minute, 0, 1)
+			state.Update(newCostRateLimitResponse(http.StatusOK, test.headers))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			err := state.Wait(ctx)
+			if waited := err != nil; waited != test.wait {
+				t.Errorf("expected wait %v, got error %v", test.wait, err)
+			}
+		})
+	}
+}
+
+func TestCostRateLimitStateWaitWithoutHeadroom(t *testing.T) {
+	// without any rate limit information requests are not paced (parallel workers are not serialized)
+	state := NewCostRateLimitState(time.Minute, 0, 0)
+
+	for i := 0; i < 10; i++ {
+		if delay := state.reserve(); delay > 0 {
+			t.Fatalf("request %v: expected no delay, got %v", i, delay)
+		}
+	}
+}